@@ -4,38 +4,76 @@ import (
 	"encoding/json"
 	"io/ioutil"
 	"strings"
+	"time"
 
+	"github.com/citihub/probr-sdk/events"
+	"github.com/citihub/probr-sdk/redact"
 	"github.com/citihub/probr-sdk/utils"
 )
 
 // Scenario is used by scenario states to audit progress through each step
 type Scenario struct {
-	Name   string
-	Result string // Passed / Failed / Given Not Met
-	Tags   []string
-	Steps  map[int]*step
+	ID               string // Derived from the probe and scenario names, so that it is stable across runs
+	Name             string
+	Result           string // Passed / Passed After Retry / Failed / Given Not Met
+	Tags             []string
+	Steps            []*step
+	PreviousAttempts []*ScenarioAttempt `json:",omitempty"` // Earlier attempts, if the scenario was retried
+	probe            *Probe             // Used to identify the probe in published events, but not publicly printed
+	retrying         bool               // Set when the next matching call to InitializeAuditor should record a new attempt
+	managed          bool               // Set when step results are recorded by the probe engine, see AuditStepResult
+	pending          *step              // Description and payload provided by a managed step, awaiting its result
+	Timing
+}
+
+// ScenarioAttempt holds the outcome of an earlier attempt of a retried scenario
+type ScenarioAttempt struct {
+	Result string
+	Steps  []*step
+	Timing
 }
 
 type step struct {
 	Function    string
 	Name        string
 	Description string      // Long-form explanation of anything happening in the step
-	Result      string      // Passed / Failed
+	Result      string      // Passed / Failed / Skipped / Undefined / Pending
 	Error       string      // Log the error text
 	Payload     interface{} // Handles any values that are sent across the network
+	Timing
 }
 
 func (e *Probe) Write() {
-	if len(e.Scenarios) > 0 && utils.WriteAllowed(e.Path) {
-		json, _ := json.MarshalIndent(e, "", "  ")
-		data := []byte(json)
+	e.lock.Lock()
+	data := e.auditJSON()
+	e.lock.Unlock()
+	e.write(data)
+}
+
+// auditJSON returns the audit to be written, or nil if there is nothing to audit. The caller must hold the probe's lock.
+func (e *Probe) auditJSON() []byte {
+	if len(e.Scenarios) == 0 && e.Error == "" {
+		return nil
+	}
+	e.SchemaVersion = SchemaVersion
+	data, _ := json.MarshalIndent(e, "", "  ")
+	return data
+}
+
+func (e *Probe) write(data []byte) {
+	if data != nil && utils.WriteAllowed(e.Path) {
 		ioutil.WriteFile(e.Path, data, 0755)
 	}
 }
 
 // AuditScenarioStep sets description, payload, and pass/fail based on err parameter.
-// This function should be deferred to catch panic behavior, otherwise the audit will not be logged on panic
+// Steps of a probe that has completed, e.g. those still running after the probe timed out, are not audited.
+// This function should be deferred to catch panic behavior, otherwise the audit will not be logged on panic.
+// If the scenario is recorded by the probe engine, only the description, payload and function are used; the step name
+// and result are taken from godog, see AuditStepResult.
+// Secrets in the description and payload are redacted, see redact.Value.
 func (p *Scenario) AuditScenarioStep(stepName, description string, payload interface{}, err error) {
+	description, payload = redact.String(description), redact.Value(payload)
 	stepFunctionName := utils.CallerName(2) // returns name if deferred and not panicking
 	switch stepFunctionName {
 	case "call":
@@ -44,24 +82,96 @@ func (p *Scenario) AuditScenarioStep(stepName, description string, payload inter
 		stepFunctionName = utils.CallerName(3) // returns name if caller panicked and this function was deferred
 	}
 
-	p.audit(stepFunctionName, stepName, description, payload, err)
-}
+	p.probe.lock.Lock()
+	if p.probe.discarding() {
+		p.probe.lock.Unlock()
+		return
+	}
+	if p.managed {
+		p.pending = &step{Function: stepFunctionName, Description: description, Payload: payload}
+		p.probe.lock.Unlock()
+		return
+	}
+	p.probe.lock.Unlock()
 
-func (p *Scenario) audit(functionName string, stepName string, description string, payload interface{}, err error) {
-	stepNumber := len(p.Steps) + 1
-	p.Steps[stepNumber] = &step{
-		Function:    functionName,
+	s := &step{
+		Function:    stepFunctionName,
 		Name:        stepName,
 		Description: description,
 		Payload:     payload,
 	}
+	p.probe.lock.Lock()
+	if p.probe.discarding() {
+		p.probe.lock.Unlock()
+		return
+	}
+	s.start(p.lastStepEnd()) // The step is audited when it ends, so is assumed to start when the previous step ended
+	s.end(time.Now())
+	p.audit(s, err)
+	e := p.stepEvent(s)
+	p.probe.lock.Unlock()
+	events.Publish(e) // Published without the lock held, so that subscribers may inspect the audit
+}
+
+// lastStepEnd returns the time the latest step ended, or the start of the scenario if no steps have been audited
+func (p *Scenario) lastStepEnd() time.Time {
+	if n := len(p.Steps); n > 0 && !p.Steps[n-1].EndTime.IsZero() {
+		return p.Steps[n-1].EndTime
+	}
+	return p.StartTime
+}
+
+// AuditStepResult records the outcome of a step as reported by the test runner, along with the description and payload
+// provided by the step via AuditScenarioStep. Result is one of Passed, Failed, Skipped, Undefined or Pending;
+// only Passed and Failed steps affect the result of the scenario. If functionName is empty, the function that called
+// AuditScenarioStep is recorded.
+func (p *Scenario) AuditStepResult(stepName, functionName, result string, duration time.Duration, err error) {
+	p.probe.lock.Lock()
+	if p.probe.discarding() {
+		p.probe.lock.Unlock()
+		return
+	}
+	s := p.pending
+	if s == nil {
+		s = &step{}
+	}
+	p.pending = nil
+	if functionName != "" {
+		s.Function = functionName
+	}
+	s.Name = stepName
+	now := time.Now()
+	s.start(now.Add(-duration))
+	s.end(now)
+
+	switch result {
+	case "Passed":
+		err = nil
+		fallthrough
+	case "Failed":
+		p.audit(s, err)
+		e := p.stepEvent(s)
+		p.probe.lock.Unlock()
+		events.Publish(e)
+	default:
+		s.Result = result
+		p.Steps = append(p.Steps, s)
+		p.end(now)
+		p.probe.lock.Unlock()
+	}
+}
+
+// audit records the step as passed, or failed if err is not nil. The caller must hold the probe's lock.
+func (p *Scenario) audit(s *step, err error) {
+	p.Steps = append(p.Steps, s)
+	p.end(s.EndTime)
 	if err == nil {
-		p.Steps[stepNumber].Result = "Passed"
-		p.Result = "Passed"
+		s.Result = "Passed"
+		p.Result = p.passedResult()
 	} else {
-		p.Steps[stepNumber].Result = "Failed"
-		p.Steps[stepNumber].Error = strings.Replace(err.Error(), "[ERROR] ", "", -1)
-		if stepNumber == 1 {
+		s.Result = "Failed"
+		s.Error = redact.String(strings.Replace(err.Error(), "[ERROR] ", "", -1))
+		if len(p.Steps) == 1 {
 			// TODO: change to handle this in AuditScenarioGiven, then here do if step.IsGiven
 			p.Result = "Given Not Met" // First entry is always a 'given'; failures should be ignored
 		} else {
@@ -69,3 +179,72 @@ func (p *Scenario) audit(functionName string, stepName string, description strin
 		}
 	}
 }
+
+// passedResult distinguishes scenarios that only passed after an earlier attempt did not
+func (p *Scenario) passedResult() string {
+	for _, attempt := range p.PreviousAttempts {
+		if attempt.Result != "Passed" {
+			return "Passed After Retry"
+		}
+	}
+	return "Passed"
+}
+
+// FailedStepErrors returns the errors of all failed steps in the current attempt
+func (p *Scenario) FailedStepErrors() (errs []string) {
+	p.probe.lock.RLock()
+	defer p.probe.lock.RUnlock()
+	for _, s := range p.Steps {
+		if s.Result == "Failed" {
+			errs = append(errs, s.Error)
+		}
+	}
+	return
+}
+
+// stepEvent returns a StepPassed or StepFailed event including the audited payload
+func (p *Scenario) stepEvent(s *step) events.Event {
+	e := events.Event{
+		Type:        events.StepPassed,
+		Scenario:    p.Name,
+		Tags:        p.Tags,
+		Step:        s.Name,
+		Description: s.Description,
+		Status:      s.Result,
+		Error:       s.Error,
+		Payload:     s.Payload,
+	}
+	if s.Result == "Failed" {
+		e.Type = events.StepFailed
+	}
+	if p.probe != nil {
+		e.Probe = p.probe.name
+		e.Pack, _ = p.probe.Meta["group"].(string)
+	}
+	return e
+}
+
+// snapshot returns a copy of the scenario and its steps, belonging to probe. The caller must hold the probe's lock.
+func (p *Scenario) snapshot(probe *Probe) *Scenario {
+	c := *p
+	c.probe = probe
+	c.pending = nil
+	c.Tags = append([]string(nil), p.Tags...)
+	c.Steps = copySteps(p.Steps)
+	c.PreviousAttempts = nil
+	for _, attempt := range p.PreviousAttempts {
+		a := *attempt
+		a.Steps = copySteps(attempt.Steps)
+		c.PreviousAttempts = append(c.PreviousAttempts, &a)
+	}
+	return &c
+}
+
+func copySteps(steps []*step) []*step {
+	c := make([]*step, len(steps))
+	for i, s := range steps {
+		copied := *s
+		c[i] = &copied
+	}
+	return c
+}
@@ -0,0 +1,288 @@
+package audit
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/citihub/probr-sdk/config"
+)
+
+const (
+	// BundleFileName is the name of the bundle written to the run directory, see WriteSummary
+	BundleFileName = "bundle.tar.gz"
+	// BundleManifestName is the name of the manifest within a bundle
+	BundleManifestName = "manifest.json"
+	// BundleSignatureName is the name of the detached signature of the manifest within a bundle
+	BundleSignatureName = "manifest.sig"
+)
+
+// bundledFiles and bundledDirs are the outputs of a run, relative to its run directory, that are included in a bundle
+var (
+	bundledFiles = []string{"summary.json", SARIFFileName, "config.json"}
+	bundledDirs  = []string{"audit", "cucumber"}
+)
+
+// BundleManifest lists the SHA-256 digest of every file in a bundle. The manifest is signed, so that
+// a change to any file in the bundle can be detected.
+type BundleManifest struct {
+	Created   time.Time
+	PublicKey string // Base64 encoded ed25519 public key of the signer
+	Files     []BundleFile
+}
+
+// BundleFile is the digest of a file within a bundle
+type BundleFile struct {
+	Path   string // Slash separated, relative to the run directory
+	SHA256 string // Hex encoded
+	Size   int64
+}
+
+// WriteBundle writes a signed bundle of the outputs in dir if a BundleKeyFile is configured. dir should be a run directory
+// as written by WriteSummary, which holds only the outputs of its run: the summary, the audits of the probes in the
+// summary, and the cucumber results written by the probe engine.
+func WriteBundle(dir string) {
+	if config.Vars.BundleKeyFile == "" {
+		return
+	}
+	key, err := ReadSigningKey(config.Vars.BundleKeyFile)
+	if err != nil {
+		log.Printf("[ERROR] Bundle was not written: %v", err)
+		return
+	}
+	bundlePath := filepath.Join(dir, BundleFileName)
+	if err := CreateBundle(dir, bundlePath, key); err != nil {
+		log.Printf("[ERROR] Bundle was not written: %v", err)
+		return
+	}
+	log.Printf("[NOTICE] Signed bundle written to file %s", bundlePath)
+}
+
+// CreateBundle packages the summary, audit, cucumber and config outputs found in dir into a gzipped tar file at bundlePath.
+// The bundle includes a manifest of the SHA-256 digest of each file, and an ed25519 signature of the manifest.
+func CreateBundle(dir, bundlePath string, key ed25519.PrivateKey) error {
+	paths, err := bundlePaths(dir)
+	if err != nil {
+		return err
+	}
+	manifest := BundleManifest{
+		Created:   time.Now().UTC(),
+		PublicKey: base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey)),
+		Files:     []BundleFile{},
+	}
+	contents := make(map[string][]byte)
+	for _, p := range paths {
+		data, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(p)))
+		if err != nil {
+			return err
+		}
+		digest := sha256.Sum256(data)
+		manifest.Files = append(manifest.Files, BundleFile{Path: p, SHA256: hex.EncodeToString(digest[:]), Size: int64(len(data))})
+		contents[p] = data
+	}
+	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	signature := base64.StdEncoding.EncodeToString(ed25519.Sign(key, manifestJSON))
+
+	f, err := os.Create(bundlePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gw)
+	add := func(name string, data []byte) error {
+		header := &tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), ModTime: manifest.Created}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		_, err := tw.Write(data)
+		return err
+	}
+	if err := add(BundleManifestName, manifestJSON); err != nil {
+		return err
+	}
+	if err := add(BundleSignatureName, []byte(signature)); err != nil {
+		return err
+	}
+	for _, p := range paths {
+		if err := add(p, contents[p]); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+// bundlePaths returns the slash separated paths of the outputs in dir to be bundled, in order
+func bundlePaths(dir string) ([]string, error) {
+	var paths []string
+	for _, name := range bundledFiles {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			paths = append(paths, name)
+		}
+	}
+	for _, name := range bundledDirs {
+		root := filepath.Join(dir, name)
+		if _, err := os.Stat(root); os.IsNotExist(err) {
+			continue
+		}
+		err := filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+			if err != nil || !info.Mode().IsRegular() {
+				return err
+			}
+			rel, err := filepath.Rel(dir, p)
+			paths = append(paths, filepath.ToSlash(rel))
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// VerifyBundle checks that the manifest of the bundle at bundlePath was signed by the private key matching key,
+// and that the bundle contains exactly the files listed in the manifest, with matching digests.
+// It returns the verified manifest, or an error describing the first problem found.
+func VerifyBundle(bundlePath string, key ed25519.PublicKey) (*BundleManifest, error) {
+	if len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("public key is not a valid ed25519 key")
+	}
+	f, err := os.Open(bundlePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("could not read bundle '%s': %v", bundlePath, err)
+	}
+	var manifestJSON, signature []byte
+	digests := make(map[string]BundleFile)
+	tr := tar.NewReader(gr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("could not read bundle '%s': %v", bundlePath, err)
+		}
+		name := path.Clean(header.Name)
+		switch name {
+		case BundleManifestName:
+			manifestJSON, err = ioutil.ReadAll(tr)
+		case BundleSignatureName:
+			signature, err = ioutil.ReadAll(tr)
+		default:
+			if _, exists := digests[name]; exists {
+				return nil, fmt.Errorf("bundle contains more than one copy of '%s'", name)
+			}
+			h := sha256.New()
+			var size int64
+			size, err = io.Copy(h, tr)
+			digests[name] = BundleFile{Path: name, SHA256: hex.EncodeToString(h.Sum(nil)), Size: size}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not read '%s' from bundle '%s': %v", name, bundlePath, err)
+		}
+	}
+
+	if manifestJSON == nil || signature == nil {
+		return nil, fmt.Errorf("bundle '%s' does not contain a signed manifest", bundlePath)
+	}
+	sig, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(signature)))
+	if err != nil || !ed25519.Verify(key, manifestJSON, sig) {
+		return nil, fmt.Errorf("signature of bundle '%s' is not valid for the provided public key", bundlePath)
+	}
+	var manifest BundleManifest
+	if err := json.Unmarshal(manifestJSON, &manifest); err != nil {
+		return nil, fmt.Errorf("could not parse manifest of bundle '%s': %v", bundlePath, err)
+	}
+	for _, expected := range manifest.Files {
+		actual, found := digests[expected.Path]
+		if !found {
+			return nil, fmt.Errorf("file '%s' listed in the manifest is missing from the bundle", expected.Path)
+		}
+		if actual != expected {
+			return nil, fmt.Errorf("file '%s' does not match the digest in the manifest", expected.Path)
+		}
+		delete(digests, expected.Path)
+	}
+	if len(digests) > 0 {
+		var unlisted []string
+		for name := range digests {
+			unlisted = append(unlisted, name)
+		}
+		sort.Strings(unlisted)
+		return nil, fmt.Errorf("files %q in the bundle are not listed in the manifest", unlisted)
+	}
+	return &manifest, nil
+}
+
+// ReadSigningKey reads a PEM encoded PKCS #8 ed25519 private key, as generated by `openssl genpkey -algorithm ed25519`
+func ReadSigningKey(path string) (ed25519.PrivateKey, error) {
+	der, err := readPEM(path, "PRIVATE KEY")
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse private key '%s': %v", path, err)
+	}
+	k, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private key '%s' is not an ed25519 key", path)
+	}
+	return k, nil
+}
+
+// ReadVerifyingKey reads a PEM encoded PKIX ed25519 public key, as generated by `openssl pkey -pubout`
+func ReadVerifyingKey(path string) (ed25519.PublicKey, error) {
+	der, err := readPEM(path, "PUBLIC KEY")
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse public key '%s': %v", path, err)
+	}
+	k, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("public key '%s' is not an ed25519 key", path)
+	}
+	return k, nil
+}
+
+func readPEM(path, blockType string) ([]byte, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != blockType {
+		return nil, fmt.Errorf("'%s' does not contain a PEM encoded %s", path, blockType)
+	}
+	return block.Bytes, nil
+}
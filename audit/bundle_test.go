@@ -0,0 +1,221 @@
+package audit
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	sdk "github.com/citihub/probr-sdk"
+	"github.com/citihub/probr-sdk/config"
+)
+
+// rewriteBundle rewrites the bundle at path, replacing the contents of each file with those returned by modify,
+// or removing the file if modify returns nil
+func rewriteBundle(t *testing.T, path string, modify func(name string, data []byte) []byte) {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	type entry struct {
+		name string
+		data []byte
+	}
+	var entries []entry
+	tr := tar.NewReader(gr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := ioutil.ReadAll(tr)
+		if data = modify(header.Name, data); data != nil {
+			entries = append(entries, entry{header.Name, data})
+		}
+	}
+	f.Close()
+
+	out, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	gw := gzip.NewWriter(out)
+	tw := tar.NewWriter(gw)
+	for _, e := range entries {
+		tw.WriteHeader(&tar.Header{Name: e.name, Mode: 0644, Size: int64(len(e.data))})
+		tw.Write(e.data)
+	}
+	tw.Close()
+	gw.Close()
+}
+
+// writeTestKeys writes a new ed25519 key pair to dir, returning the paths of the private and public keys
+func writeTestKeys(t *testing.T, dir string) (keyPath, publicKeyPath string) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, _ := x509.MarshalPKCS8PrivateKey(private)
+	keyPath = writeTestFile(t, dir, "key.pem", string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})))
+	der, _ = x509.MarshalPKIXPublicKey(public)
+	publicKeyPath = writeTestFile(t, dir, "key.pub.pem", string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})))
+	return
+}
+
+func TestBundle(t *testing.T) {
+	dir, err := ioutil.TempDir("", "probr-bundle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	keyPath, publicKeyPath := writeTestKeys(t, dir)
+	config.Vars.BundleKeyFile = keyPath
+	defer func() {
+		config.Vars.BundleKeyFile = ""
+	}()
+
+	output := filepath.Join(dir, "run")
+	writeTestFile(t, output, "summary.json", `{"Status": "Complete"}`)
+	writeTestFile(t, output, "config.json", `{}`)
+	writeTestFile(t, output, filepath.Join("audit", "probe.json"), `{"Result": "Success"}`)
+	writeTestFile(t, output, filepath.Join("cucumber", "probe.json"), `[]`)
+	writeTestFile(t, output, filepath.Join("checkpoints", "pack.json"), `{}`)
+
+	tests := []struct {
+		testName      string
+		modify        func(name string, data []byte) []byte
+		expectedError string
+	}{
+		{
+			testName: "VerifyBundle_WithUnmodifiedBundle_ShouldSucceed",
+			modify:   func(name string, data []byte) []byte { return data },
+		},
+		{
+			testName: "VerifyBundle_WithModifiedFile_ShouldFail",
+			modify: func(name string, data []byte) []byte {
+				if name == "audit/probe.json" {
+					return []byte(`{"Result": "Failed"}`)
+				}
+				return data
+			},
+			expectedError: "does not match the digest",
+		},
+		{
+			testName: "VerifyBundle_WithModifiedManifest_ShouldFail",
+			modify: func(name string, data []byte) []byte {
+				if name == BundleManifestName {
+					return []byte(strings.Replace(string(data), "audit/probe.json", "audit/other.json", 1))
+				}
+				return data
+			},
+			expectedError: "signature",
+		},
+		{
+			testName: "VerifyBundle_WithRemovedFile_ShouldFail",
+			modify: func(name string, data []byte) []byte {
+				if name == "summary.json" {
+					return nil
+				}
+				return data
+			},
+			expectedError: "missing from the bundle",
+		},
+	}
+
+	key, err := ReadVerifyingKey(publicKeyPath)
+	if err != nil {
+		t.Fatalf("ReadVerifyingKey() returned unexpected error: %v", err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			WriteBundle(output)
+			bundlePath := filepath.Join(output, BundleFileName)
+			rewriteBundle(t, bundlePath, tt.modify)
+			manifest, err := VerifyBundle(bundlePath, key)
+			if tt.expectedError == "" {
+				if err != nil {
+					t.Fatalf("VerifyBundle() returned unexpected error: %v", err)
+				}
+				var paths []string
+				for _, f := range manifest.Files {
+					paths = append(paths, f.Path)
+				}
+				expected := "audit/probe.json,config.json,cucumber/probe.json,summary.json"
+				if strings.Join(paths, ",") != expected {
+					t.Errorf("Expected bundle to contain %s, but found %v", expected, paths)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), tt.expectedError) {
+				t.Errorf("VerifyBundle() error = %v, Expected error containing '%s'", err, tt.expectedError)
+			}
+		})
+	}
+
+	t.Run("VerifyBundle_WithDifferentKey_ShouldFail", func(t *testing.T) {
+		WriteBundle(output)
+		other, _, _ := ed25519.GenerateKey(rand.Reader)
+		if _, err := VerifyBundle(filepath.Join(output, BundleFileName), other); err == nil {
+			t.Errorf("Expected VerifyBundle() to fail with a different public key")
+		}
+	})
+}
+
+func TestWriteSummary_WithBundleKeyFile_ShouldBundleRunDirectory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "probr-bundle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	installDir := sdk.GlobalConfig.InstallDir
+	keyPath, publicKeyPath := writeTestKeys(t, dir)
+	config.Vars.WriteDirectory = filepath.Join(dir, "write")
+	config.Vars.BundleKeyFile = keyPath
+	sdk.GlobalConfig.InstallDir = filepath.Join(dir, "install")
+	defer func() {
+		os.RemoveAll(dir) // Delete test data after tests
+		config.Vars.WriteDirectory = ""
+		config.Vars.BundleKeyFile = ""
+		sdk.GlobalConfig.InstallDir = installDir
+	}()
+
+	// The layout left by a run: the shared audit directory holds the audits of earlier runs too,
+	// and the probe engine writes cucumber results to the run directory
+	writeTestFile(t, config.Vars.AuditDir(), "stale_probe.json", `{"Result": "Success"}`)
+	writeTestFile(t, config.Vars.GetWriteDirectory(), "config.json", `{}`)
+	writeTestFile(t, filepath.Join(sdk.GlobalConfig.OutputDir(), "cucumber"), "probe.json", `[]`)
+	summary := NewSummaryState("test_pack")
+	summary.GetProbeLog("probe").InitializeAuditor("scenario", nil).AuditScenarioStep("a step", "", nil, nil)
+	summary.ProbeComplete("probe")
+	summary.WriteSummary()
+
+	key, err := ReadVerifyingKey(publicKeyPath)
+	if err != nil {
+		t.Fatalf("ReadVerifyingKey() returned unexpected error: %v", err)
+	}
+	manifest, err := VerifyBundle(filepath.Join(sdk.GlobalConfig.OutputDir(), BundleFileName), key)
+	if err != nil {
+		t.Fatalf("VerifyBundle() returned unexpected error: %v", err)
+	}
+	var paths []string
+	for _, f := range manifest.Files {
+		paths = append(paths, f.Path)
+	}
+	expected := "audit/probe.json,config.json,cucumber/probe.json,results.sarif,summary.json"
+	if strings.Join(paths, ",") != expected {
+		t.Errorf("Expected bundle to contain %s, but found %v", expected, paths)
+	}
+}
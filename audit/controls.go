@@ -0,0 +1,193 @@
+package audit
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"strings"
+
+	"github.com/citihub/probr-sdk/config"
+	"gopkg.in/yaml.v2"
+)
+
+// Results of a control in the compliance rollup
+const (
+	ControlPassed       = "Passed"       // Every covering scenario passed
+	ControlFailed       = "Failed"       // At least one covering scenario failed
+	ControlInconclusive = "Inconclusive" // No covering scenario failed, but not all passed, e.g. a 'given' was not met
+	ControlNotCovered   = "Not Covered"  // No scenario in the run covers the control
+)
+
+// ControlCatalogue maps the controls of compliance frameworks, such as the CIS Kubernetes Benchmark or NIST 800-53,
+// to the tags of the scenarios that evidence them. For example:
+//
+//	Frameworks:
+//	  - Name: CIS Kubernetes Benchmark
+//	    Version: "1.6"
+//	    Controls:
+//	      - ID: "5.2.1"
+//	        Title: Minimize the admission of privileged containers
+//	        Tags: ["@probes/kubernetes/pod_security/1.1"]
+type ControlCatalogue struct {
+	Frameworks []Framework `yaml:"Frameworks"`
+}
+
+// Framework is a set of controls, e.g. a regulatory standard or benchmark
+type Framework struct {
+	Name     string    `yaml:"Name"`
+	Version  string    `yaml:"Version"`
+	Controls []Control `yaml:"Controls"`
+}
+
+// Control is evidenced by every scenario that has any of its tags
+type Control struct {
+	ID    string   `yaml:"ID"`
+	Title string   `yaml:"Title"`
+	Tags  []string `yaml:"Tags"` // Scenario or feature tags, with or without the leading '@'
+}
+
+// Compliance is the rollup of scenario results by control
+type Compliance struct {
+	ControlsPassed       int
+	ControlsFailed       int
+	ControlsInconclusive int
+	ControlsNotCovered   int
+	Controls             []*ControlResult // In catalogue order
+}
+
+// ControlResult is the result of a control, and the scenarios that evidence it
+type ControlResult struct {
+	Framework string
+	ID        string
+	Title     string
+	Result    string // See ControlPassed, ControlFailed, ControlInconclusive and ControlNotCovered
+	Scenarios []ControlScenario
+}
+
+// ControlScenario identifies a scenario covering a control
+type ControlScenario struct {
+	Probe      string
+	ScenarioID string
+	Name       string
+	Result     string
+}
+
+// ReadControlCatalogue reads a control catalogue from a YAML file
+func ReadControlCatalogue(path string) (*ControlCatalogue, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c := &ControlCatalogue{}
+	if err := yaml.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("could not parse control catalogue '%s': %v", path, err)
+	}
+	for _, f := range c.Frameworks {
+		for _, control := range f.Controls {
+			if control.ID == "" {
+				return nil, fmt.Errorf("control catalogue '%s' has a control without an ID in framework '%s'", path, f.Name)
+			}
+		}
+	}
+	return c, nil
+}
+
+// configuredControls reads the catalogue in the ControlsFile, if one is configured
+func configuredControls() *ControlCatalogue {
+	if config.Vars.ControlsFile == "" {
+		return nil
+	}
+	c, err := ReadControlCatalogue(config.Vars.ControlsFile)
+	if err != nil {
+		log.Printf("[ERROR] Could not read ControlsFile; compliance will not be included in the summary: %v", err)
+		return nil
+	}
+	return c
+}
+
+// SetControls sets the catalogue used to roll up the results of scenarios by control, see Compliance.
+// By default, the catalogue in the configured ControlsFile is used.
+func (s *SummaryState) SetControls(c *ControlCatalogue) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.controls = c
+}
+
+// Compliance returns the result of each control in the catalogue, resolved against the tags of the audited scenarios,
+// or nil if no catalogue is set
+func (s *SummaryState) Compliance() *Compliance {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.compliance()
+}
+
+// compliance is Compliance for callers that already hold the lock
+func (s *SummaryState) compliance() *Compliance {
+	if s.controls == nil {
+		return nil
+	}
+	c := &Compliance{Controls: []*ControlResult{}}
+	names := s.probeNames()
+	for _, f := range s.controls.Frameworks {
+		for _, control := range f.Controls {
+			result := &ControlResult{Framework: f.Name, ID: control.ID, Title: control.Title, Scenarios: []ControlScenario{}}
+			for _, name := range names {
+				for _, scenario := range s.Probes[name].Scenarios {
+					if control.covers(scenario) {
+						result.Scenarios = append(result.Scenarios, ControlScenario{
+							Probe:      name,
+							ScenarioID: scenario.ID,
+							Name:       scenario.Name,
+							Result:     scenario.Result,
+						})
+					}
+				}
+			}
+			result.Result = result.rollup()
+			switch result.Result {
+			case ControlPassed:
+				c.ControlsPassed++
+			case ControlFailed:
+				c.ControlsFailed++
+			case ControlInconclusive:
+				c.ControlsInconclusive++
+			default:
+				c.ControlsNotCovered++
+			}
+			c.Controls = append(c.Controls, result)
+		}
+	}
+	return c
+}
+
+// covers is true if the scenario has any of the control's tags
+func (c Control) covers(s *Scenario) bool {
+	for _, tag := range c.Tags {
+		if !strings.HasPrefix(tag, "@") {
+			tag = "@" + tag
+		}
+		for _, scenarioTag := range s.Tags {
+			if scenarioTag == tag {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (r *ControlResult) rollup() string {
+	if len(r.Scenarios) == 0 {
+		return ControlNotCovered
+	}
+	result := ControlPassed
+	for _, s := range r.Scenarios {
+		switch s.Result {
+		case "Failed":
+			return ControlFailed
+		case "Passed", "Passed After Retry":
+		default:
+			result = ControlInconclusive
+		}
+	}
+	return result
+}
@@ -0,0 +1,113 @@
+package audit
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/citihub/probr-sdk/config"
+)
+
+const controlCatalogue = `
+Frameworks:
+  - Name: CIS Kubernetes Benchmark
+    Version: "1.6"
+    Controls:
+      - ID: "5.2.1"
+        Title: Minimize the admission of privileged containers
+        Tags: ["@probes/kubernetes/pod_security/1.1"]
+      - ID: "5.2.4"
+        Title: Minimize the admission of containers wishing to share the host network namespace
+        Tags: ["probes/kubernetes/pod_security/1.2"]
+  - Name: NIST 800-53
+    Controls:
+      - ID: AC-6
+        Title: Least Privilege
+        Tags: ["@probes/kubernetes/pod_security"]
+      - ID: SC-7
+        Title: Boundary Protection
+        Tags: ["@probes/kubernetes/network"]
+`
+
+func TestSummaryState_Compliance(t *testing.T) {
+	dir, err := ioutil.TempDir("", "probr-controls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Vars.WriteDirectory = dir
+	config.Vars.ControlsFile = writeTestFile(t, dir, "controls.yaml", controlCatalogue)
+	defer func() {
+		os.RemoveAll(dir) // Delete test data after tests
+		config.Vars.WriteDirectory = ""
+		config.Vars.ControlsFile = ""
+	}()
+
+	summary := NewSummaryState("kubernetes")
+	summary.LogProbeStart("pod_security")
+	probe := summary.GetProbeLog("pod_security")
+	passed := probe.InitializeAuditor("privileged containers are denied", tags("@probes/kubernetes/pod_security", "@probes/kubernetes/pod_security/1.1"))
+	passed.AuditScenarioStep("a cluster exists", "", nil, nil)
+	passed.AuditScenarioStep("a privileged pod is denied", "", nil, nil)
+	failed := probe.InitializeAuditor("host network is denied", tags("@probes/kubernetes/pod_security", "@probes/kubernetes/pod_security/1.2"))
+	failed.AuditScenarioStep("a cluster exists", "", nil, nil)
+	failed.AuditScenarioStep("a pod using the host network is denied", "", nil, errors.New("pod was created"))
+	summary.ProbeComplete("pod_security")
+
+	c := summary.Compliance()
+	if c == nil {
+		t.Fatalf("Expected compliance to be rolled up using the configured ControlsFile")
+	}
+	tests := []struct {
+		framework         string
+		id                string
+		expectedResult    string
+		expectedScenarios int
+	}{
+		{"CIS Kubernetes Benchmark", "5.2.1", ControlPassed, 1},
+		{"CIS Kubernetes Benchmark", "5.2.4", ControlFailed, 1},
+		{"NIST 800-53", "AC-6", ControlFailed, 2},
+		{"NIST 800-53", "SC-7", ControlNotCovered, 0},
+	}
+	if len(c.Controls) != len(tests) {
+		t.Fatalf("Expected a result for each of the %d controls, but found %d", len(tests), len(c.Controls))
+	}
+	for i, tt := range tests {
+		t.Run("Compliance_"+tt.id, func(t *testing.T) {
+			result := c.Controls[i]
+			if result.Framework != tt.framework || result.ID != tt.id {
+				t.Fatalf("Expected control %s %s, but found %s %s", tt.framework, tt.id, result.Framework, result.ID)
+			}
+			if result.Result != tt.expectedResult || len(result.Scenarios) != tt.expectedScenarios {
+				t.Errorf("Expected result %s with %d scenarios, but found %s with %d", tt.expectedResult, tt.expectedScenarios, result.Result, len(result.Scenarios))
+			}
+		})
+	}
+	if c.ControlsPassed != 1 || c.ControlsFailed != 2 || c.ControlsInconclusive != 0 || c.ControlsNotCovered != 1 {
+		t.Errorf("Unexpected compliance totals: %+v", c)
+	}
+
+	var written limitedSummaryState
+	json.Unmarshal(summary.summary(), &written)
+	if written.Compliance == nil || len(written.Compliance.Controls) != len(tests) {
+		t.Errorf("Expected compliance to be included in the summary")
+	}
+
+	summary.SetControls(nil)
+	if summary.Compliance() != nil {
+		t.Errorf("Expected no compliance without a control catalogue")
+	}
+}
+
+func TestReadControlCatalogue_WithoutControlID_ShouldFail(t *testing.T) {
+	dir, err := ioutil.TempDir("", "probr-controls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := writeTestFile(t, dir, "controls.yaml", "Frameworks:\n  - Name: Custom\n    Controls:\n      - Title: Untitled\n")
+	if _, err := ReadControlCatalogue(path); err == nil {
+		t.Errorf("Expected ReadControlCatalogue() to return an error for a control without an ID")
+	}
+}
@@ -0,0 +1,233 @@
+package audit
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	sdk "github.com/citihub/probr-sdk"
+	"github.com/citihub/probr-sdk/utils"
+)
+
+// Diff lists the scenarios whose results changed between two runs. Scenarios are matched by probe and scenario ID,
+// which is stable across runs; see Scenario.ID.
+type Diff struct {
+	Old           string // Summary of the earlier run
+	New           string // Summary of the later run
+	NewlyFailing  []ScenarioChange
+	NewlyPassing  []ScenarioChange
+	Added         []ScenarioChange // Scenarios only found in the later run
+	Removed       []ScenarioChange // Scenarios only found in the earlier run
+	ChangedErrors []ScenarioChange // Scenarios that failed in both runs, but with different step errors
+}
+
+// ScenarioChange is a scenario whose result changed between runs
+type ScenarioChange struct {
+	Probe      string
+	ScenarioID string
+	Name       string
+	OldResult  string       `json:",omitempty"`
+	NewResult  string       `json:",omitempty"`
+	Steps      []StepChange `json:",omitempty"` // Steps whose errors changed
+}
+
+// StepChange is a step whose error changed between runs
+type StepChange struct {
+	Index    int // Position of the step in the scenario, from 0; distinguishes steps with the same name
+	Name     string
+	OldError string
+	NewError string
+}
+
+// DiffRuns compares the results of two runs. Each run is a summary.json file, a directory containing it such as a
+// run directory (see WriteSummary), the ID of a run in the output directory, or "latest" for the most recent run.
+// The audit of each probe is read as it is by MergeSummaries.
+func DiffRuns(oldRun, newRun string) (*Diff, error) {
+	oldPath, err := summaryPath(oldRun)
+	if err != nil {
+		return nil, err
+	}
+	newPath, err := summaryPath(newRun)
+	if err != nil {
+		return nil, err
+	}
+	oldSummary, err := MergeSummaries(oldPath)
+	if err != nil {
+		return nil, err
+	}
+	newSummary, err := MergeSummaries(newPath)
+	if err != nil {
+		return nil, err
+	}
+	d := &Diff{
+		Old:           oldPath,
+		New:           newPath,
+		NewlyFailing:  []ScenarioChange{},
+		NewlyPassing:  []ScenarioChange{},
+		Added:         []ScenarioChange{},
+		Removed:       []ScenarioChange{},
+		ChangedErrors: []ScenarioChange{},
+	}
+
+	oldScenarios := make(map[string]*Scenario)
+	for _, name := range oldSummary.probeNames() {
+		for _, s := range oldSummary.Probes[name].Scenarios {
+			oldScenarios[name+"/"+s.ID] = s
+		}
+	}
+	matched := make(map[string]bool)
+	for _, name := range newSummary.probeNames() {
+		for _, s := range newSummary.Probes[name].Scenarios {
+			key := name + "/" + s.ID
+			change := ScenarioChange{Probe: name, ScenarioID: s.ID, Name: s.Name, NewResult: s.Result}
+			old, found := oldScenarios[key]
+			if !found {
+				d.Added = append(d.Added, change)
+				continue
+			}
+			matched[key] = true
+			change.OldResult = old.Result
+			change.Steps = changedSteps(old, s)
+			switch {
+			case !failed(old) && failed(s):
+				d.NewlyFailing = append(d.NewlyFailing, change)
+			case failed(old) && passed(s):
+				change.Steps = nil
+				d.NewlyPassing = append(d.NewlyPassing, change)
+			case failed(old) && failed(s) && len(change.Steps) > 0:
+				d.ChangedErrors = append(d.ChangedErrors, change)
+			}
+		}
+	}
+	for _, name := range oldSummary.probeNames() {
+		for _, s := range oldSummary.Probes[name].Scenarios {
+			if !matched[name+"/"+s.ID] {
+				d.Removed = append(d.Removed, ScenarioChange{Probe: name, ScenarioID: s.ID, Name: s.Name, OldResult: s.Result})
+			}
+		}
+	}
+	return d, nil
+}
+
+// summaryPath returns the path of the summary of a run, see DiffRuns
+func summaryPath(run string) (string, error) {
+	if info, err := os.Stat(run); err == nil {
+		if info.IsDir() {
+			return filepath.Join(run, "summary.json"), nil
+		}
+		return run, nil
+	}
+	var dir string
+	var err error
+	if run == sdk.LatestFileName {
+		dir, err = sdk.GlobalConfig.LatestOutputDir()
+	} else {
+		dir, err = sdk.GlobalConfig.RunOutputDir(run)
+	}
+	if err != nil {
+		return "", fmt.Errorf("could not find run '%s': %v", run, err)
+	}
+	return filepath.Join(dir, "summary.json"), nil
+}
+
+func failed(s *Scenario) bool {
+	return s.Result == "Failed"
+}
+
+func passed(s *Scenario) bool {
+	return s.Result == "Passed" || s.Result == "Passed After Retry"
+}
+
+// stepKey identifies a step by its position and name, so that steps with the same text are compared separately
+type stepKey struct {
+	index int
+	name  string
+}
+
+// changedSteps compares the errors of the failed steps of each scenario, in the order they were run
+func changedSteps(before, after *Scenario) (changes []StepChange) {
+	oldErrors, newErrors := stepErrors(before), stepErrors(after)
+	var keys []stepKey
+	seen := make(map[stepKey]bool)
+	for _, steps := range [][]*step{before.Steps, after.Steps} {
+		for i, s := range steps {
+			if key := (stepKey{i, s.Name}); !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.SliceStable(keys, func(i, j int) bool { return keys[i].index < keys[j].index })
+	for _, key := range keys {
+		if oldErrors[key] != newErrors[key] {
+			changes = append(changes, StepChange{Index: key.index, Name: key.name, OldError: oldErrors[key], NewError: newErrors[key]})
+		}
+	}
+	return
+}
+
+func stepErrors(s *Scenario) map[stepKey]string {
+	errs := make(map[stepKey]string)
+	for i, step := range s.Steps {
+		if step.Result == "Failed" {
+			errs[stepKey{i, step.Name}] = step.Error
+		}
+	}
+	return errs
+}
+
+// Empty is true if no scenario changed between the runs
+func (d *Diff) Empty() bool {
+	return len(d.NewlyFailing)+len(d.NewlyPassing)+len(d.Added)+len(d.Removed)+len(d.ChangedErrors) == 0
+}
+
+// JSON returns the diff formatted as JSON
+func (d *Diff) JSON() []byte {
+	return utils.JSON(d)
+}
+
+// String returns a human-readable report of the diff
+func (d *Diff) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Comparing %s with %s\n", d.Old, d.New)
+	if d.Empty() {
+		b.WriteString("No scenarios changed\n")
+		return b.String()
+	}
+	sections := []struct {
+		title   string
+		changes []ScenarioChange
+	}{
+		{"Newly failing", d.NewlyFailing},
+		{"Newly passing", d.NewlyPassing},
+		{"Changed errors", d.ChangedErrors},
+		{"Added", d.Added},
+		{"Removed", d.Removed},
+	}
+	for _, section := range sections {
+		if len(section.changes) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "\n%s (%d):\n", section.title, len(section.changes))
+		for _, c := range section.changes {
+			fmt.Fprintf(&b, "  %s: %s", c.Probe, c.Name)
+			if c.OldResult != "" && c.NewResult != "" {
+				fmt.Fprintf(&b, " (%s -> %s)\n", c.OldResult, c.NewResult)
+			} else {
+				fmt.Fprintf(&b, " (%s%s)\n", c.OldResult, c.NewResult) // Only one is set for added and removed scenarios
+			}
+			for _, s := range c.Steps {
+				fmt.Fprintf(&b, "      %d. %s\n", s.Index+1, s.Name)
+				if s.OldError != "" {
+					fmt.Fprintf(&b, "        - %s\n", s.OldError)
+				}
+				if s.NewError != "" {
+					fmt.Fprintf(&b, "        + %s\n", s.NewError)
+				}
+			}
+		}
+	}
+	return b.String()
+}
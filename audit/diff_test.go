@@ -0,0 +1,160 @@
+package audit
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	sdk "github.com/citihub/probr-sdk"
+)
+
+const diffSummary = `{"SchemaVersion": 2, "Probes": [{"Name": "pod_security", "Path": "audit/pod_security.json", "Result": "Failed"}]}`
+
+const oldDiffAudit = `{
+  "SchemaVersion": 2,
+  "Scenarios": [
+    {"ID": "a", "Name": "privileged pods are denied", "Result": "Passed", "Steps": []},
+    {"ID": "b", "Name": "host network is denied", "Result": "Failed", "Steps": [{"Name": "the pod is denied", "Result": "Failed", "Error": "pod was created"}]},
+    {"ID": "c", "Name": "host PID is denied", "Result": "Failed", "Steps": [{"Name": "the pod is denied", "Result": "Failed", "Error": "pod was created"}]},
+    {"ID": "d", "Name": "host IPC is denied", "Result": "Failed", "Steps": [{"Name": "the pod is denied", "Result": "Failed", "Error": "pod was created"}]},
+    {"ID": "e", "Name": "removed scenario", "Result": "Passed", "Steps": []}
+  ]
+}`
+
+const newDiffAudit = `{
+  "SchemaVersion": 2,
+  "Scenarios": [
+    {"ID": "a", "Name": "privileged pods are denied", "Result": "Failed", "Steps": [{"Name": "the pod is denied", "Result": "Failed", "Error": "pod was created"}]},
+    {"ID": "b", "Name": "host network is denied", "Result": "Passed After Retry", "Steps": []},
+    {"ID": "c", "Name": "host PID is denied", "Result": "Failed", "Steps": [{"Name": "the pod is denied", "Result": "Failed", "Error": "timed out"}]},
+    {"ID": "d", "Name": "host IPC is denied", "Result": "Failed", "Steps": [{"Name": "the pod is denied", "Result": "Failed", "Error": "pod was created"}]},
+    {"ID": "f", "Name": "added scenario", "Result": "Passed", "Steps": []}
+  ]
+}`
+
+func TestDiffRuns(t *testing.T) {
+	dir, err := ioutil.TempDir("", "probr-diff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	oldRun, newRun := filepath.Join(dir, "old"), filepath.Join(dir, "new")
+	writeTestFile(t, oldRun, "summary.json", diffSummary)
+	writeTestFile(t, oldRun, filepath.Join("audit", "pod_security.json"), oldDiffAudit)
+	writeTestFile(t, newRun, "summary.json", diffSummary)
+	writeTestFile(t, newRun, filepath.Join("audit", "pod_security.json"), newDiffAudit)
+
+	d, err := DiffRuns(oldRun, filepath.Join(newRun, "summary.json"))
+	if err != nil {
+		t.Fatalf("DiffRuns() returned unexpected error: %v", err)
+	}
+	tests := []struct {
+		testName string
+		changes  []ScenarioChange
+		expected string
+	}{
+		{"DiffRuns_WithNewFailure_ShouldReportNewlyFailing", d.NewlyFailing, "a"},
+		{"DiffRuns_WithNewPass_ShouldReportNewlyPassing", d.NewlyPassing, "b"},
+		{"DiffRuns_WithDifferentError_ShouldReportChangedErrors", d.ChangedErrors, "c"},
+		{"DiffRuns_WithNewScenario_ShouldReportAdded", d.Added, "f"},
+		{"DiffRuns_WithMissingScenario_ShouldReportRemoved", d.Removed, "e"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			if len(tt.changes) != 1 || tt.changes[0].ScenarioID != tt.expected || tt.changes[0].Probe != "pod_security" {
+				t.Errorf("Expected only scenario '%s', but found %+v", tt.expected, tt.changes)
+			}
+		})
+	}
+
+	expectedStep := StepChange{Index: 0, Name: "the pod is denied", OldError: "pod was created", NewError: "timed out"}
+	if len(d.ChangedErrors) == 1 && (len(d.ChangedErrors[0].Steps) != 1 || d.ChangedErrors[0].Steps[0] != expectedStep) {
+		t.Errorf("Expected changed step %+v, but found %+v", expectedStep, d.ChangedErrors[0].Steps)
+	}
+	text := d.String()
+	for _, expected := range []string{"Newly failing (1):", "pod_security: host network is denied (Failed -> Passed After Retry)", "1. the pod is denied", "- pod was created", "+ timed out", "added scenario (Passed)"} {
+		if !strings.Contains(text, expected) {
+			t.Errorf("Expected report to contain '%s', but found:\n%s", expected, text)
+		}
+	}
+	var parsed Diff
+	if err := json.Unmarshal(d.JSON(), &parsed); err != nil || len(parsed.Removed) != 1 {
+		t.Errorf("Expected diff to be formatted as JSON, but found %s", d.JSON())
+	}
+
+	same, err := DiffRuns(newRun, newRun)
+	if err != nil || !same.Empty() || !strings.Contains(same.String(), "No scenarios changed") {
+		t.Errorf("Expected no changes between a run and itself, but found %v, %v", same, err)
+	}
+}
+
+func TestDiffRuns_WithRunIDs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "probr-diff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(gc sdk.GlobalOpts) {
+		os.RemoveAll(dir)
+		sdk.GlobalConfig = gc
+	}(sdk.GlobalConfig)
+	sdk.GlobalConfig.InstallDir = dir
+
+	oldID := sdk.NewRunID(time.Date(2021, 11, 1, 9, 0, 0, 0, time.UTC))
+	oldRun, err := sdk.GlobalConfig.RunOutputDir(oldID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, oldRun, "summary.json", diffSummary)
+	writeTestFile(t, oldRun, filepath.Join("audit", "pod_security.json"), oldDiffAudit)
+	sdk.GlobalConfig.StartTime = time.Date(2021, 11, 2, 9, 0, 0, 0, time.UTC)
+	sdk.GlobalConfig.RunID = sdk.NewRunID(sdk.GlobalConfig.StartTime)
+	writeTestFile(t, sdk.GlobalConfig.OutputDir(), "summary.json", diffSummary)
+	writeTestFile(t, sdk.GlobalConfig.OutputDir(), filepath.Join("audit", "pod_security.json"), newDiffAudit)
+	if err := sdk.GlobalConfig.SetLatest(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		testName       string
+		oldRun, newRun string
+		expectErr      bool
+	}{
+		{"DiffRuns_WithRunIDs_ShouldCompareRunDirectories", oldID, sdk.GlobalConfig.RunID, false},
+		{"DiffRuns_WithLatest_ShouldCompareMostRecentRun", oldID, sdk.LatestFileName, false},
+		{"DiffRuns_WithUnknownRun_ShouldReturnError", "not-a-run", sdk.LatestFileName, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			d, err := DiffRuns(tt.oldRun, tt.newRun)
+			if tt.expectErr {
+				if err == nil {
+					t.Errorf("Expected an error, but found %v", d)
+				}
+				return
+			}
+			if err != nil || len(d.NewlyFailing) != 1 || len(d.Added) != 1 {
+				t.Errorf("DiffRuns() = %v, %v, Expected the changes between the runs", d, err)
+			}
+		})
+	}
+}
+
+func TestChangedSteps_WithRepeatedStepNames_ShouldCompareEachStep(t *testing.T) {
+	before := &Scenario{Steps: []*step{
+		{Name: "the pod is denied", Result: "Passed"},
+		{Name: "the pod is denied", Result: "Failed", Error: "pod was created"},
+	}}
+	after := &Scenario{Steps: []*step{
+		{Name: "the pod is denied", Result: "Failed", Error: "timed out"},
+		{Name: "the pod is denied", Result: "Failed", Error: "pod was created"},
+	}}
+	changes := changedSteps(before, after)
+	expected := StepChange{Index: 0, Name: "the pod is denied", NewError: "timed out"}
+	if len(changes) != 1 || changes[0] != expected {
+		t.Errorf("changedSteps() = %+v, Expected only %+v", changes, expected)
+	}
+}
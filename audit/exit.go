@@ -0,0 +1,118 @@
+package audit
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/citihub/probr-sdk/config"
+)
+
+// Exit codes returned by ExitPolicy.Evaluate. If a run breaks the policy in several ways, the lowest non-zero code is returned.
+const (
+	ExitPassed         = 0 // The run met the policy
+	ExitFailed         = 1 // A scenario failed, or a probe could not be completed
+	ExitError          = 2 // Probr could not execute the probes; never returned by Evaluate, see probeengine.ProbeStore.RunAllProbes
+	ExitBelowPassRate  = 3 // Fewer scenarios passed than the policy's MinPassRate
+	ExitSkippedOrUnmet = 4 // A probe was skipped or excluded, or a scenario's 'given' was not met
+)
+
+// Values of ExitPolicy.FailOn
+const (
+	FailOnAny      = "any"      // Any failed scenario fails the run
+	FailOnCritical = "critical" // Only failed scenarios with one of the policy's CriticalTags fail the run
+	FailOnNone     = "none"     // Failed scenarios only affect the run through the pass rate
+)
+
+// ExitPolicy decides the exit code of a run from its summary, so that CI can distinguish a clean run from one in which
+// probes were skipped or scenarios could not be tested. The zero value fails the run on any failed scenario.
+type ExitPolicy struct {
+	FailOn        string   // FailOnAny, FailOnCritical or FailOnNone
+	MinPassRate   float64  // From 0 to 1, counting only passed and failed scenarios; 0 means no threshold
+	FailOnSkipped bool     // Skipped or excluded probes, and scenarios whose 'given' was not met, fail the run
+	CriticalTags  []string // Scenario or feature tags, with or without the leading '@'
+}
+
+// ConfiguredExitPolicy returns the policy set by the ExitPolicy config options
+func ConfiguredExitPolicy() ExitPolicy {
+	return ExitPolicy{
+		FailOn:        config.Vars.GetExitFailOn(),
+		MinPassRate:   config.Vars.GetExitMinPassRate(),
+		FailOnSkipped: strings.ToLower(config.Vars.ExitPolicy.FailOnSkipped) == "true",
+		CriticalTags:  config.Vars.ExitPolicy.CriticalTags,
+	}
+}
+
+// ExitCode evaluates the configured exit policy against the summary, logging the reasons for a non-zero exit code.
+// It should be called once every probe has completed, as probeengine.ProbeStore.RunAllProbes does.
+func (s *SummaryState) ExitCode() int {
+	code, reasons := ConfiguredExitPolicy().Evaluate(s)
+	for _, reason := range reasons {
+		log.Printf("[NOTICE] Exit code %d: %s", code, reason)
+	}
+	return code
+}
+
+// Evaluate returns the exit code of the run according to the policy, and the reasons it did not return ExitPassed.
+// Probes that could not be completed, e.g. due to a timeout, fail the run unless FailOn is FailOnNone.
+func (p ExitPolicy) Evaluate(s *SummaryState) (code int, reasons []string) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	var failed, unmet []string
+	var passed, total, skipped int
+	for _, name := range s.probeNames() {
+		probe := s.Probes[name]
+		switch probe.Result {
+		case "Excluded", "Dependency Failed", "No Scenarios Executed":
+			skipped++
+		}
+		if probe.Error != "" && p.FailOn != FailOnNone {
+			failed = append(failed, fmt.Sprintf("probe '%s' could not be completed", name))
+		}
+		for _, scenario := range probe.Scenarios {
+			id := fmt.Sprintf("'%s' in probe '%s'", scenario.Name, name)
+			switch scenario.Result {
+			case "Passed", "Passed After Retry":
+				passed++
+				total++
+			case "Failed":
+				total++
+				if p.FailOn == FailOnNone || (p.FailOn == FailOnCritical && !p.critical(scenario)) {
+					continue
+				}
+				failed = append(failed, fmt.Sprintf("scenario %s failed", id))
+			case "Given Not Met":
+				unmet = append(unmet, id)
+			}
+		}
+	}
+
+	if len(failed) > 0 {
+		code = ExitFailed
+		reasons = append(reasons, failed...)
+	}
+	if p.MinPassRate > 0 && total > 0 && float64(passed)/float64(total) < p.MinPassRate {
+		if code == ExitPassed {
+			code = ExitBelowPassRate
+		}
+		reasons = append(reasons, fmt.Sprintf("%d/%d scenarios passed, below the minimum pass rate of %g%%", passed, total, p.MinPassRate*100))
+	}
+	if p.FailOnSkipped && (skipped > 0 || len(unmet) > 0) {
+		if code == ExitPassed {
+			code = ExitSkippedOrUnmet
+		}
+		if skipped > 0 {
+			reasons = append(reasons, fmt.Sprintf("%d probes were skipped", skipped))
+		}
+		for _, id := range unmet {
+			reasons = append(reasons, fmt.Sprintf("the 'given' of scenario %s was not met", id))
+		}
+	}
+	return
+}
+
+// critical is true if the scenario has any of the policy's critical tags
+func (p ExitPolicy) critical(scenario *Scenario) bool {
+	return Control{Tags: p.CriticalTags}.covers(scenario)
+}
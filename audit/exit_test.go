@@ -0,0 +1,142 @@
+package audit
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/citihub/probr-sdk/config"
+)
+
+func TestExitPolicy_Evaluate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "probr-exit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Vars.WriteDirectory = dir
+	defer func() {
+		os.RemoveAll(dir) // Delete test data after tests
+		config.Vars.WriteDirectory = ""
+	}()
+
+	// newSummary returns a run in which each scenario passes, fails, or fails its 'given'
+	newSummary := func(results map[string]string, excluded bool) *SummaryState {
+		summary := NewSummaryState("kubernetes")
+		summary.LogProbeStart("pod_security")
+		probe := summary.GetProbeLog("pod_security")
+		for name, result := range results {
+			scenario := probe.InitializeAuditor(name, tags("@probes/kubernetes/pod_security", "@severity/"+name))
+			var givenErr, thenErr error
+			switch result {
+			case "Given Not Met":
+				givenErr = errors.New("no cluster")
+			case "Failed":
+				thenErr = errors.New("pod was created")
+			}
+			scenario.AuditScenarioStep("a cluster exists", "", nil, givenErr)
+			if givenErr == nil { // Later steps are skipped if the 'given' is not met
+				scenario.AuditScenarioStep("the pod is denied", "", nil, thenErr)
+			}
+		}
+		summary.ProbeComplete("pod_security")
+		if excluded {
+			summary.LogProbeResult("network", "Excluded")
+			summary.ProbeComplete("network")
+		}
+		return &summary
+	}
+
+	critical := []string{"@severity/critical"}
+	tests := []struct {
+		testName string
+		policy   ExitPolicy
+		results  map[string]string // Scenario names, which are also their severity, and results
+		excluded bool
+		expected int
+		reasons  int
+	}{
+		{
+			testName: "Evaluate_WithAllPassed_ShouldReturnPassed",
+			results:  map[string]string{"critical": "Passed", "low": "Passed"},
+			expected: ExitPassed,
+		},
+		{
+			testName: "Evaluate_WithZeroValuePolicy_ShouldFailOnAnyFailure",
+			results:  map[string]string{"critical": "Passed", "low": "Failed"},
+			expected: ExitFailed,
+			reasons:  1,
+		},
+		{
+			testName: "Evaluate_WithFailOnCritical_ShouldIgnoreOtherFailures",
+			policy:   ExitPolicy{FailOn: FailOnCritical, CriticalTags: critical},
+			results:  map[string]string{"critical": "Passed", "low": "Failed"},
+			expected: ExitPassed,
+		},
+		{
+			testName: "Evaluate_WithFailOnCritical_ShouldFailOnCriticalFailure",
+			policy:   ExitPolicy{FailOn: FailOnCritical, CriticalTags: []string{"severity/critical"}},
+			results:  map[string]string{"critical": "Failed", "low": "Passed"},
+			expected: ExitFailed,
+			reasons:  1,
+		},
+		{
+			testName: "Evaluate_WithPassRateBelowThreshold_ShouldReturnBelowPassRate",
+			policy:   ExitPolicy{FailOn: FailOnNone, MinPassRate: 0.75},
+			results:  map[string]string{"critical": "Passed", "low": "Failed", "medium": "Given Not Met"},
+			expected: ExitBelowPassRate,
+			reasons:  1,
+		},
+		{
+			testName: "Evaluate_WithPassRateAtThreshold_ShouldReturnPassed",
+			policy:   ExitPolicy{FailOn: FailOnNone, MinPassRate: 0.5},
+			results:  map[string]string{"critical": "Passed", "low": "Failed"},
+			expected: ExitPassed,
+		},
+		{
+			testName: "Evaluate_WithoutFailOnSkipped_ShouldIgnoreSkippedAndUnmet",
+			results:  map[string]string{"critical": "Passed", "medium": "Given Not Met"},
+			excluded: true,
+			expected: ExitPassed,
+		},
+		{
+			testName: "Evaluate_WithFailOnSkipped_ShouldReturnSkippedOrUnmet",
+			policy:   ExitPolicy{FailOnSkipped: true},
+			results:  map[string]string{"critical": "Passed", "medium": "Given Not Met"},
+			excluded: true,
+			expected: ExitSkippedOrUnmet,
+			reasons:  2,
+		},
+		{
+			testName: "Evaluate_WithSeveralBreaches_ShouldReturnLowestCode",
+			policy:   ExitPolicy{MinPassRate: 1, FailOnSkipped: true},
+			results:  map[string]string{"critical": "Failed", "medium": "Given Not Met"},
+			expected: ExitFailed,
+			reasons:  3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			code, reasons := tt.policy.Evaluate(newSummary(tt.results, tt.excluded))
+			if code != tt.expected || len(reasons) != tt.reasons {
+				t.Errorf("Evaluate() = %d, %q, Expected: %d with %d reasons", code, reasons, tt.expected, tt.reasons)
+			}
+		})
+	}
+}
+
+func TestConfiguredExitPolicy(t *testing.T) {
+	defer func() {
+		config.Vars.ExitPolicy = config.ExitPolicy{}
+	}()
+	config.Vars.ExitPolicy = config.ExitPolicy{FailOn: "Critical", MinPassRate: "90%", FailOnSkipped: "true", CriticalTags: []string{"@critical"}}
+	p := ConfiguredExitPolicy()
+	if p.FailOn != FailOnCritical || p.MinPassRate != 0.9 || !p.FailOnSkipped || len(p.CriticalTags) != 1 {
+		t.Errorf("ConfiguredExitPolicy() = %+v, Expected the configured options", p)
+	}
+	config.Vars.ExitPolicy = config.ExitPolicy{FailOn: "sometimes", MinPassRate: "150"}
+	p = ConfiguredExitPolicy()
+	if p.FailOn != FailOnAny || p.MinPassRate != 0 || p.FailOnSkipped {
+		t.Errorf("ConfiguredExitPolicy() = %+v, Expected invalid options to fall back to failing on any failure", p)
+	}
+}
@@ -1,56 +1,198 @@
 package audit
 
 import (
+	"sync"
+	"time"
+
 	"github.com/cucumber/messages-go/v10"
 )
 
 // Probe is passed through various functions to audit the probe's progress
 type Probe struct {
+	SchemaVersion      int // See SchemaVersion; set when the audit is written
 	name               string
 	Meta               map[string]interface{}
 	Path               string
 	ScenariosAttempted int
 	ScenariosSucceeded int
 	ScenariosFailed    int
+	ScenariosRetried   int // Scenarios that passed only after being retried; also included in ScenariosSucceeded
 	Result             string
-	Scenarios          map[int]*Scenario
+	Error              string // Set if the probe could not be completed, e.g. due to a timeout
+	Scenarios          []*Scenario
+	pickles            map[string]*Scenario // Scenarios recorded by the probe engine's scenario hooks, keyed by pickle ID
+	lock               *sync.RWMutex        // Shared with the summary, if the probe belongs to one
+	closed             bool                 // Set when the probe completes; its scenarios can no longer change
+	paused             int                  // Greater than zero while the probe's scenarios are run without being audited; see Pause
+	Timing
 }
 
 type limitedProbe struct {
+	Name               string                 `json:"Name"`
 	Meta               map[string]interface{} `json:"Meta"`
 	Path               string                 `json:"Path"`
 	ScenariosAttempted int                    `json:"ScenariosAttempted"`
 	ScenariosSucceeded int                    `json:"ScenariosSucceeded"`
 	ScenariosFailed    int                    `json:"ScenariosFailed"`
+	ScenariosRetried   int                    `json:"ScenariosRetried"`
 	Result             string                 `json:"Result"`
+	Error              string                 `json:"Error,omitempty"`
+	Timing
+}
+
+// limited returns the fields of the probe that are included in the summary
+func (e *Probe) limited(name string) *limitedProbe {
+	return &limitedProbe{
+		Name:               name,
+		Meta:               e.Meta,
+		Path:               e.Path,
+		ScenariosAttempted: e.ScenariosAttempted,
+		ScenariosSucceeded: e.ScenariosSucceeded,
+		ScenariosFailed:    e.ScenariosFailed,
+		ScenariosRetried:   e.ScenariosRetried,
+		Result:             e.Result,
+		Error:              e.Error,
+		Timing:             e.Timing,
+	}
 }
 
 // countResults stores the current total number of failures as e.ScenariosFailed. Run at probe end
 func (e *Probe) countResults() {
 	e.ScenariosAttempted = len(e.Scenarios)
+	e.ScenariosSucceeded, e.ScenariosFailed, e.ScenariosRetried = 0, 0, 0
 	for _, v := range e.Scenarios {
 		if v.Result == "Failed" {
 			e.ScenariosFailed = e.ScenariosFailed + 1
 		} else if v.Result == "Passed" {
 			e.ScenariosSucceeded = e.ScenariosSucceeded + 1
+		} else if v.Result == "Passed After Retry" {
+			e.ScenariosSucceeded = e.ScenariosSucceeded + 1
+			e.ScenariosRetried = e.ScenariosRetried + 1
 		}
 	}
 }
 
-// InitializeAuditor creates a new audit entry for the specified scenario
+// InitializeAuditor creates a new audit entry for the specified scenario,
+// or returns the existing entry if the scenario is being retried (see RetryScenario).
+// Once the probe has completed, the entry returned is not part of its audit.
 func (e *Probe) InitializeAuditor(name string, tags []*messages.Pickle_PickleTag) *Scenario {
-	if e.Scenarios == nil {
-		e.Scenarios = make(map[int]*Scenario)
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return e.initializeAuditor(name, tags)
+}
+
+// Pause stops the probe's audit from changing until the returned function is called, e.g. while its scenarios are run
+// again only to write results in another format. Anything audited in the meantime is discarded.
+func (e *Probe) Pause() (resume func()) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.paused++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			e.lock.Lock()
+			defer e.lock.Unlock()
+			e.paused--
+		})
+	}
+}
+
+// discarding is true if nothing may be audited, as the probe has completed or is paused. The caller must hold the lock.
+func (e *Probe) discarding() bool {
+	return e.closed || e.paused > 0
+}
+
+func (e *Probe) initializeAuditor(name string, tags []*messages.Pickle_PickleTag) *Scenario {
+	if e.discarding() {
+		return &Scenario{Name: name, Steps: []*step{}, probe: e} // Not part of the audit
+	}
+	occurrence := 1
+	for _, s := range e.Scenarios {
+		if s.Name != name {
+			continue
+		}
+		if s.retrying {
+			s.retrying = false
+			s.start(time.Now())
+			return s
+		}
+		occurrence++
 	}
-	i := len(e.Scenarios) + 1
 	var t []string
 	for _, tag := range tags {
 		t = append(t, tag.Name)
 	}
-	e.Scenarios[i] = &Scenario{
+	s := &Scenario{
+		ID:    scenarioID(e.name, name, occurrence),
 		Name:  name,
-		Steps: make(map[int]*step),
+		Steps: []*step{},
 		Tags:  t,
+		probe: e,
+	}
+	s.start(time.Now())
+	e.Scenarios = append(e.Scenarios, s)
+	return s
+}
+
+// PickleAuditor returns the audit entry for a scenario run by the probe engine, whose scenario hooks record the result of
+// each step (see AuditStepResult). An entry created for the scenario by InitializeAuditor, e.g. in a BeforeScenario hook, is reused.
+func (e *Probe) PickleAuditor(pickle *messages.Pickle) *Scenario {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if e.discarding() {
+		return e.initializeAuditor(pickle.Name, pickle.Tags)
+	}
+	if e.pickles == nil {
+		e.pickles = make(map[string]*Scenario)
+	}
+	if s, found := e.pickles[pickle.Id]; found && s.Name == pickle.Name {
+		if s.retrying { // Pickle IDs are stable across attempts of the same feature file
+			s.retrying = false
+			s.start(time.Now())
+		}
+		return s
+	}
+	var s *Scenario
+	for i := len(e.Scenarios) - 1; i >= 0; i-- {
+		if e.Scenarios[i].Name == pickle.Name {
+			if !e.Scenarios[i].managed && len(e.Scenarios[i].Steps) == 0 {
+				s = e.Scenarios[i]
+			}
+			break
+		}
+	}
+	if s == nil {
+		s = e.initializeAuditor(pickle.Name, pickle.Tags)
+	}
+	s.managed = true
+	e.pickles[pickle.Id] = s
+	return s
+}
+
+// RetryScenario moves the current attempt of each scenario with the given name into its PreviousAttempts,
+// so that the steps of the next attempt are audited against the same Scenario
+func (e *Probe) RetryScenario(name string) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	for _, s := range e.Scenarios {
+		if s.Name == name {
+			s.PreviousAttempts = append(s.PreviousAttempts, &ScenarioAttempt{Result: s.Result, Steps: s.Steps, Timing: s.Timing})
+			s.Result = ""
+			s.Steps = []*step{}
+			s.retrying = true
+		}
+	}
+}
+
+// snapshot returns a copy of the probe and its scenarios, guarded by lock. The caller must hold the probe's lock.
+func (e *Probe) snapshot(lock *sync.RWMutex) *Probe {
+	c := *e
+	c.lock = lock
+	c.pickles = nil
+	c.Meta = copyMeta(e.Meta)
+	c.Scenarios = make([]*Scenario, len(e.Scenarios))
+	for i, s := range e.Scenarios {
+		c.Scenarios[i] = s.snapshot(&c)
 	}
-	return e.Scenarios[i]
+	return &c
 }
@@ -0,0 +1,245 @@
+package audit
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"github.com/citihub/probr-sdk/config"
+	"github.com/citihub/probr-sdk/utils"
+)
+
+const (
+	// SARIFFileName is the name of the SARIF log written alongside the summary, see WriteSummary
+	SARIFFileName = "results.sarif"
+
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifBaseID  = "PROBR_OUTPUT" // Locations are relative to the write directory
+
+	severityTagPrefix = "@severity/"
+	defaultSeverity   = "high" // Applies to failed scenarios without a severity tag
+)
+
+// severities map the value of a scenario's severity tag, e.g. @severity/medium, to a SARIF level and a
+// security-severity score, as used by GitHub code scanning to rank alerts
+var severities = map[string]struct {
+	level string
+	score string
+}{
+	"critical": {"error", "9.5"},
+	"high":     {"error", "8.0"},
+	"medium":   {"warning", "5.5"},
+	"low":      {"note", "3.0"},
+	"info":     {"note", "0.0"},
+}
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool               sarifTool                        `json:"tool"`
+	OriginalURIBaseIDs map[string]sarifArtifactLocation `json:"originalUriBaseIds,omitempty"`
+	Results            []sarifResult                    `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string             `json:"id"`
+	Name                 string             `json:"name"`
+	ShortDescription     sarifMessage       `json:"shortDescription"`
+	DefaultConfiguration sarifConfiguration `json:"defaultConfiguration"`
+	Properties           sarifProperties    `json:"properties"`
+}
+
+type sarifConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifProperties struct {
+	Tags             []string `json:"tags,omitempty"`
+	SecuritySeverity string   `json:"security-severity"`
+}
+
+type sarifResult struct {
+	RuleID              string            `json:"ruleId"`
+	RuleIndex           int               `json:"ruleIndex"`
+	Level               string            `json:"level"`
+	Message             sarifMessage      `json:"message"`
+	Locations           []sarifLocation   `json:"locations,omitempty"`
+	PartialFingerprints map[string]string `json:"partialFingerprints"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation *sarifPhysicalLocation `json:"physicalLocation,omitempty"`
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+}
+
+type sarifArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId,omitempty"`
+}
+
+type sarifLogicalLocation struct {
+	Name               string `json:"name"`
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind"`
+}
+
+// WriteSARIF writes the failed scenarios of every probe to the write directory as a SARIF log, see SARIF
+func (s *SummaryState) WriteSARIF() {
+	dir := config.Vars.GetWriteDirectory()
+	path := filepath.Join(dir, SARIFFileName)
+	if utils.WriteAllowed(path) {
+		if err := ioutil.WriteFile(path, s.SARIF(dir), 0755); err != nil {
+			log.Printf("[ERROR] SARIF log was not written: %v", err)
+		}
+	}
+}
+
+// SARIF returns a SARIF 2.1.0 log with a result for each failed scenario, ordered by probe.
+// The rule of a result is the scenario's most specific @probes/ tag, e.g. probes/kubernetes/general/1.0,
+// and its level is taken from the scenario's @severity/<critical|high|medium|low|info> tag.
+// The errors of the failed steps form the message, and the location is the audit file of the probe, relative to dir.
+func (s *SummaryState) SARIF(dir string) []byte {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	driver := sarifDriver{
+		Name:           "Probr",
+		InformationURI: "https://github.com/citihub/probr",
+		Rules:          []sarifRule{},
+	}
+	ruleIndex := make(map[string]int)
+	results := []sarifResult{}
+	for _, name := range s.probeNames() {
+		probe := s.Probes[name]
+		for _, scenario := range probe.Scenarios {
+			if scenario.Result != "Failed" {
+				continue
+			}
+			rule := scenario.sarifRule(name)
+			index, found := ruleIndex[rule.ID]
+			if !found {
+				index = len(driver.Rules)
+				ruleIndex[rule.ID] = index
+				driver.Rules = append(driver.Rules, rule)
+			}
+			results = append(results, sarifResult{
+				RuleID:              rule.ID,
+				RuleIndex:           index,
+				Level:               rule.DefaultConfiguration.Level,
+				Message:             sarifMessage{Text: scenario.sarifMessage()},
+				Locations:           []sarifLocation{probe.sarifLocation(name, scenario, dir)},
+				PartialFingerprints: map[string]string{"probrScenarioId/v1": name + "/" + scenario.ID},
+			})
+		}
+	}
+	return utils.JSON(sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs: []sarifRun{{
+			Tool:               sarifTool{Driver: driver},
+			OriginalURIBaseIDs: map[string]sarifArtifactLocation{sarifBaseID: {URI: strings.TrimSuffix(fileURI(dir), "/") + "/"}},
+			Results:            results,
+		}},
+	})
+}
+
+// sarifRule describes the control tested by the scenario. The caller must hold the probe's lock.
+func (p *Scenario) sarifRule(probeName string) sarifRule {
+	rule := sarifRule{
+		ID:               fmt.Sprintf("probes/%s/%s", probeName, p.ID), // Used if the scenario has no @probes/ tag
+		Name:             p.Name,
+		ShortDescription: sarifMessage{Text: p.Name},
+	}
+	severity, segments := defaultSeverity, 0
+	for _, tag := range p.Tags {
+		switch {
+		case strings.HasPrefix(tag, "@probes/"):
+			// Feature tags are inherited by scenarios, so the tag with the most segments identifies the scenario
+			if n := strings.Count(tag, "/"); n > segments {
+				rule.ID, segments = strings.TrimPrefix(tag, "@"), n
+			}
+		case strings.HasPrefix(tag, severityTagPrefix):
+			if value := strings.ToLower(strings.TrimPrefix(tag, severityTagPrefix)); severities[value].level != "" {
+				severity = value // Scenario tags follow, and so override, feature tags
+			} else {
+				log.Printf("[WARN] Unknown severity tag '%s' on scenario '%s'", tag, p.Name)
+			}
+		default:
+			rule.Properties.Tags = append(rule.Properties.Tags, strings.TrimPrefix(tag, "@"))
+		}
+	}
+	rule.DefaultConfiguration.Level = severities[severity].level
+	rule.Properties.SecuritySeverity = severities[severity].score
+	rule.Properties.Tags = append(rule.Properties.Tags, "security")
+	return rule
+}
+
+// sarifMessage returns the errors of the failed steps. The caller must hold the probe's lock.
+func (p *Scenario) sarifMessage() string {
+	var errs []string
+	for _, s := range p.Steps {
+		if s.Result == "Failed" && s.Error != "" {
+			errs = append(errs, fmt.Sprintf("%s: %s", s.Name, s.Error))
+		}
+	}
+	if len(errs) == 0 {
+		return fmt.Sprintf("Scenario '%s' failed", p.Name)
+	}
+	return fmt.Sprintf("Scenario '%s' failed. %s", p.Name, strings.Join(errs, "; "))
+}
+
+// sarifLocation identifies the scenario within the probe's audit file. The caller must hold the probe's lock.
+func (e *Probe) sarifLocation(probeName string, scenario *Scenario, dir string) sarifLocation {
+	location := sarifLocation{
+		LogicalLocations: []sarifLogicalLocation{{
+			Name:               scenario.Name,
+			FullyQualifiedName: probeName + "/" + scenario.ID,
+			Kind:               "test",
+		}},
+	}
+	if e.Path == "" {
+		return location
+	}
+	artifact := sarifArtifactLocation{URI: fileURI(e.Path)}
+	if rel, err := filepath.Rel(dir, e.Path); err == nil && !strings.HasPrefix(rel, "..") {
+		artifact = sarifArtifactLocation{URI: filepath.ToSlash(rel), URIBaseID: sarifBaseID}
+	}
+	location.PhysicalLocation = &sarifPhysicalLocation{ArtifactLocation: artifact}
+	return location
+}
+
+// fileURI returns the file URI of the path, which is made absolute if it is relative to the working directory
+func fileURI(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	path = filepath.ToSlash(path)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path // Windows paths begin with a drive letter
+	}
+	return "file://" + path
+}
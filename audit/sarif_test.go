@@ -0,0 +1,175 @@
+package audit
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/citihub/probr-sdk/config"
+	"github.com/cucumber/messages-go/v10"
+)
+
+func tags(names ...string) (t []*messages.Pickle_PickleTag) {
+	for _, name := range names {
+		t = append(t, &messages.Pickle_PickleTag{Name: name})
+	}
+	return
+}
+
+func TestSummaryState_WriteSARIF(t *testing.T) {
+	dir, err := ioutil.TempDir("", "probr-sarif")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Vars.WriteDirectory = dir
+	defer func() {
+		os.RemoveAll(dir) // Delete test data after tests
+		config.Vars.WriteDirectory = ""
+	}()
+
+	summary := NewSummaryState("test_pack")
+	summary.LogProbeStart("pod_security")
+	probe := summary.GetProbeLog("pod_security")
+
+	failed := probe.InitializeAuditor("privileged containers are denied", tags("@probes/kubernetes/pod_security", "@k-psp", "@probes/kubernetes/pod_security/1.1", "@severity/critical"))
+	failed.AuditScenarioStep("a cluster exists", "", nil, nil)
+	failed.AuditScenarioStep("a privileged pod is created", "", nil, errors.New("[ERROR] pod was created"))
+
+	untagged := probe.InitializeAuditor("host network is denied", nil)
+	untagged.AuditScenarioStep("a cluster exists", "", nil, nil)
+	untagged.AuditScenarioStep("a pod is created", "", nil, errors.New("pod was created"))
+
+	passed := probe.InitializeAuditor("host PID is denied", tags("@probes/kubernetes/pod_security/1.3"))
+	passed.AuditScenarioStep("a cluster exists", "", nil, nil)
+
+	notMet := probe.InitializeAuditor("host IPC is denied", tags("@probes/kubernetes/pod_security/1.4"))
+	notMet.AuditScenarioStep("a cluster exists", "", nil, errors.New("no cluster"))
+	summary.ProbeComplete("pod_security")
+
+	summary.WriteSARIF()
+	data, err := ioutil.ReadFile(filepath.Join(config.Vars.GetWriteDirectory(), SARIFFileName))
+	if err != nil {
+		t.Fatalf("Expected SARIF log to be written: %v", err)
+	}
+	var log sarifLog
+	if err := json.Unmarshal(data, &log); err != nil {
+		t.Fatalf("Could not parse SARIF log: %v", err)
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("Expected a single SARIF 2.1.0 run, but found version %s with %d runs", log.Version, len(log.Runs))
+	}
+	run := log.Runs[0]
+	if len(run.Results) != 2 || len(run.Tool.Driver.Rules) != 2 {
+		t.Fatalf("Expected a result and rule for each failed scenario, but found %d results and %d rules", len(run.Results), len(run.Tool.Driver.Rules))
+	}
+
+	tests := []struct {
+		testName        string
+		result          sarifResult
+		expectedRuleID  string
+		expectedLevel   string
+		expectedMessage string
+	}{
+		{
+			testName:        "SARIF_WithTaggedScenario_ShouldUseTags",
+			result:          run.Results[0],
+			expectedRuleID:  "probes/kubernetes/pod_security/1.1",
+			expectedLevel:   "error",
+			expectedMessage: "Scenario 'privileged containers are denied' failed. a privileged pod is created: pod was created",
+		},
+		{
+			testName:        "SARIF_WithUntaggedScenario_ShouldUseDefaults",
+			result:          run.Results[1],
+			expectedRuleID:  "probes/pod_security/" + untagged.ID,
+			expectedLevel:   severities[defaultSeverity].level,
+			expectedMessage: "Scenario 'host network is denied' failed. a pod is created: pod was created",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			if tt.result.RuleID != tt.expectedRuleID {
+				t.Errorf("RuleID = %s, Expected: %s", tt.result.RuleID, tt.expectedRuleID)
+			}
+			if rule := run.Tool.Driver.Rules[tt.result.RuleIndex]; rule.ID != tt.result.RuleID {
+				t.Errorf("Expected rule at index %d to be %s, but found %s", tt.result.RuleIndex, tt.result.RuleID, rule.ID)
+			}
+			if tt.result.Level != tt.expectedLevel {
+				t.Errorf("Level = %s, Expected: %s", tt.result.Level, tt.expectedLevel)
+			}
+			if tt.result.Message.Text != tt.expectedMessage {
+				t.Errorf("Message = %s, Expected: %s", tt.result.Message.Text, tt.expectedMessage)
+			}
+			location := tt.result.Locations[0].PhysicalLocation
+			if location == nil || location.ArtifactLocation.URI != "audit/pod_security.json" || location.ArtifactLocation.URIBaseID != sarifBaseID {
+				t.Errorf("Expected result to be located in the probe's audit file, but found %+v", location)
+			}
+		})
+	}
+
+	rule := run.Tool.Driver.Rules[run.Results[0].RuleIndex]
+	if rule.Properties.SecuritySeverity != "9.5" || len(rule.Properties.Tags) != 2 || rule.Properties.Tags[0] != "k-psp" {
+		t.Errorf("Expected rule properties to include the severity score and remaining tags, but found %+v", rule.Properties)
+	}
+}
+
+func TestSummaryState_SARIF_WithRelativeDirectory_ShouldUseAbsoluteURIs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "probr-sarif")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wd, _ := os.Getwd()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	config.Vars.WriteDirectory = "probr_output"
+	defer func() {
+		os.Chdir(wd)
+		os.RemoveAll(dir) // Delete test data after tests
+		config.Vars.WriteDirectory = ""
+	}()
+	abs, _ := os.Getwd() // The temporary directory may be reached through a symlink
+
+	summary := NewSummaryState("test_pack")
+	failed := summary.GetProbeLog("pod_security").InitializeAuditor("privileged containers are denied", nil)
+	failed.AuditScenarioStep("a cluster exists", "", nil, nil)
+	failed.AuditScenarioStep("a privileged pod is created", "", nil, errors.New("pod was created"))
+	summary.ProbeComplete("pod_security")
+
+	tests := []struct {
+		testName            string
+		dir                 string
+		expectedBaseURI     string
+		expectedArtifactURI string
+	}{
+		{
+			testName:            "SARIF_WithAuditInRelativeDirectory_ShouldLocateAuditRelativeToAbsoluteBase",
+			dir:                 config.Vars.GetWriteDirectory(),
+			expectedBaseURI:     fileURI(filepath.Join(abs, config.Vars.GetWriteDirectory())) + "/",
+			expectedArtifactURI: "audit/pod_security.json",
+		},
+		{
+			testName:            "SARIF_WithAuditOutsideRelativeDirectory_ShouldLocateAuditByAbsoluteURI",
+			dir:                 "elsewhere",
+			expectedBaseURI:     fileURI(filepath.Join(abs, "elsewhere")) + "/",
+			expectedArtifactURI: fileURI(filepath.Join(abs, config.Vars.AuditDir(), "pod_security.json")),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			var log sarifLog
+			if err := json.Unmarshal(summary.SARIF(tt.dir), &log); err != nil {
+				t.Fatalf("Could not parse SARIF log: %v", err)
+			}
+			run := log.Runs[0]
+			if base := run.OriginalURIBaseIDs[sarifBaseID].URI; base != tt.expectedBaseURI {
+				t.Errorf("Base URI = %s, Expected: %s", base, tt.expectedBaseURI)
+			}
+			if location := run.Results[0].Locations[0].PhysicalLocation; location == nil || location.ArtifactLocation.URI != tt.expectedArtifactURI {
+				t.Errorf("Expected result to be located at %s, but found %+v", tt.expectedArtifactURI, location)
+			}
+		})
+	}
+}
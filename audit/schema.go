@@ -0,0 +1,176 @@
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// SchemaVersion is the version of the audit and summary files written by this package.
+// Files written before the schema was versioned keyed scenarios and steps by their position in a map,
+// and are read as version 1; see ReadProbeAudit.
+const SchemaVersion = 2
+
+// scenarioID derives a stable ID for a scenario from the name of its probe and its own name.
+// The probe name is used rather than the feature, as service packs create audit entries without knowing their feature.
+// Occurrence distinguishes scenarios of the same name, such as those generated from a scenario outline.
+func scenarioID(probe, name string, occurrence int) string {
+	id := fmt.Sprintf("%x", sha256.Sum256([]byte(probe+"\n"+name)))[:12]
+	if occurrence > 1 {
+		id = fmt.Sprintf("%s-%d", id, occurrence)
+	}
+	return id
+}
+
+// ReadProbeAudit reads the audit file written for a probe by any version of Probr.
+// The probe is named after the file, as audit files are named after their probe.
+func ReadProbeAudit(path string) (*Probe, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	p := &Probe{
+		lock: &sync.RWMutex{},
+		name: strings.TrimSuffix(filepath.Base(path), ".json"),
+		Meta: make(map[string]interface{}),
+	}
+	if err := p.readAudit(data); err != nil {
+		return nil, fmt.Errorf("could not parse audit '%s': %v", path, err)
+	}
+	return p, nil
+}
+
+// legacyProbe is the version 1 audit file, in which scenarios and steps were keyed by their 1-based position
+type legacyProbe struct {
+	*Probe
+	Scenarios map[int]*legacyScenario
+}
+
+type legacyScenario struct {
+	*Scenario
+	Steps            map[int]*step
+	PreviousAttempts []*legacyScenarioAttempt
+}
+
+type legacyScenarioAttempt struct {
+	*ScenarioAttempt
+	Steps map[int]*step
+}
+
+// readAudit populates the probe from the contents of an audit file, migrating it to the current schema if necessary
+func (e *Probe) readAudit(data []byte) error {
+	version, err := schemaVersion(data)
+	if err != nil {
+		return err
+	}
+	if version > 1 {
+		if err := json.Unmarshal(data, e); err != nil {
+			return err
+		}
+	} else {
+		legacy := legacyProbe{Probe: e}
+		if err := json.Unmarshal(data, &legacy); err != nil {
+			return err
+		}
+		e.Scenarios = nil
+		occurrences := make(map[string]int)
+		var keys []int
+		for key := range legacy.Scenarios {
+			keys = append(keys, key)
+		}
+		sort.Ints(keys)
+		for _, key := range keys {
+			s := legacy.Scenarios[key]
+			if s.Scenario == nil {
+				s.Scenario = &Scenario{}
+			}
+			occurrences[s.Name]++
+			s.ID = scenarioID(e.name, s.Name, occurrences[s.Name])
+			s.Scenario.Steps = legacySteps(s.Steps)
+			s.Scenario.PreviousAttempts = nil
+			for _, attempt := range s.PreviousAttempts {
+				if attempt.ScenarioAttempt == nil {
+					attempt.ScenarioAttempt = &ScenarioAttempt{}
+				}
+				attempt.ScenarioAttempt.Steps = legacySteps(attempt.Steps)
+				s.Scenario.PreviousAttempts = append(s.Scenario.PreviousAttempts, attempt.ScenarioAttempt)
+			}
+			e.Scenarios = append(e.Scenarios, s.Scenario)
+		}
+	}
+	e.SchemaVersion = SchemaVersion
+	for _, scenario := range e.Scenarios {
+		scenario.probe = e
+	}
+	return nil
+}
+
+// legacySteps orders steps that were keyed by their 1-based position
+func legacySteps(steps map[int]*step) []*step {
+	var keys []int
+	for key := range steps {
+		keys = append(keys, key)
+	}
+	sort.Ints(keys)
+	ordered := []*step{}
+	for _, key := range keys {
+		ordered = append(ordered, steps[key])
+	}
+	return ordered
+}
+
+// legacySummary is the version 1 summary file, in which probes were keyed by name
+type legacySummary struct {
+	*limitedSummaryState
+	Probes map[string]*limitedProbe
+}
+
+// readSummary reads a summary file written by any version of Probr, migrating it to the current schema if necessary
+func readSummary(path string) (*limitedSummaryState, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	version, err := schemaVersion(data)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse summary '%s': %v", path, err)
+	}
+	summary := &limitedSummaryState{}
+	if version > 1 {
+		err = json.Unmarshal(data, summary)
+	} else {
+		legacy := legacySummary{limitedSummaryState: summary}
+		err = json.Unmarshal(data, &legacy)
+		summary.Probes = nil
+		for name, p := range legacy.Probes {
+			p.Name = name
+			summary.Probes = append(summary.Probes, p)
+		}
+		sort.Slice(summary.Probes, func(i, j int) bool { return summary.Probes[i].Name < summary.Probes[j].Name })
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not parse summary '%s': %v", path, err)
+	}
+	summary.SchemaVersion = SchemaVersion
+	return summary, nil
+}
+
+// schemaVersion returns the schema version of an audit or summary file, which is 1 if it was not recorded
+func schemaVersion(data []byte) (int, error) {
+	var v struct{ SchemaVersion int }
+	if err := json.Unmarshal(data, &v); err != nil {
+		return 0, err
+	}
+	if v.SchemaVersion > SchemaVersion {
+		return 0, fmt.Errorf("schema version %d is newer than the supported version %d", v.SchemaVersion, SchemaVersion)
+	}
+	if v.SchemaVersion < 1 {
+		return 1, nil
+	}
+	return v.SchemaVersion, nil
+}
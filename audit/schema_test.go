@@ -0,0 +1,125 @@
+package audit
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const legacyAudit = `{
+  "Meta": {"group": "kubernetes"},
+  "Path": "audit/legacy_probe.json",
+  "ScenariosAttempted": 3,
+  "Result": "Failed",
+  "Scenarios": {
+    "10": {"Name": "outline", "Result": "Passed", "Steps": {}},
+    "2": {"Name": "outline", "Result": "Failed", "Steps": {
+      "2": {"Name": "second step", "Result": "Failed", "Error": "failed"},
+      "1": {"Name": "first step", "Result": "Passed"}
+    }},
+    "1": {"Name": "retried", "Result": "Passed After Retry", "Steps": {"1": {"Name": "a step", "Result": "Passed"}},
+      "PreviousAttempts": [{"Result": "Failed", "Steps": {"1": {"Name": "a step", "Result": "Failed"}}}]}
+  }
+}`
+
+const legacySummaryFile = `{
+  "Status": "Complete - 0/2 Succeeded (0 Skipped)",
+  "Probes": {
+    "z_probe": {"Path": "audit/z_probe.json", "Result": "Success"},
+    "legacy_probe": {"Path": "audit/legacy_probe.json", "Result": "Failed"}
+  }
+}`
+
+func writeTestFile(t *testing.T, dir, name, content string) string {
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadProbeAudit_WithLegacyAudit_ShouldOrderScenariosAndSteps(t *testing.T) {
+	dir, err := ioutil.TempDir("", "probr-audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	p, err := ReadProbeAudit(writeTestFile(t, dir, "legacy_probe.json", legacyAudit))
+	if err != nil {
+		t.Fatalf("ReadProbeAudit() returned unexpected error: %v", err)
+	}
+	if p.SchemaVersion != SchemaVersion {
+		t.Errorf("Expected audit to be migrated to schema version %d, but found %d", SchemaVersion, p.SchemaVersion)
+	}
+	expected := []struct {
+		name, result, id string
+		steps            []string
+	}{
+		{"retried", "Passed After Retry", scenarioID("legacy_probe", "retried", 1), []string{"a step"}},
+		{"outline", "Failed", scenarioID("legacy_probe", "outline", 1), []string{"first step", "second step"}},
+		{"outline", "Passed", scenarioID("legacy_probe", "outline", 2), nil},
+	}
+	if len(p.Scenarios) != len(expected) {
+		t.Fatalf("Expected %d scenarios, but found %d", len(expected), len(p.Scenarios))
+	}
+	for i, e := range expected {
+		s := p.Scenarios[i]
+		if s.Name != e.name || s.Result != e.result || s.ID != e.id || s.probe != p {
+			t.Errorf("Expected scenario %d to be %s (%s) with ID %s, but found %+v", i, e.name, e.result, e.id, s)
+		}
+		if len(s.Steps) != len(e.steps) {
+			t.Errorf("Expected scenario %d to have %d steps, but found %d", i, len(e.steps), len(s.Steps))
+			continue
+		}
+		for j, name := range e.steps {
+			if s.Steps[j].Name != name {
+				t.Errorf("Expected step %d of scenario %d to be '%s', but found '%s'", j, i, name, s.Steps[j].Name)
+			}
+		}
+	}
+	if len(p.Scenarios[0].PreviousAttempts) != 1 || p.Scenarios[0].PreviousAttempts[0].Steps[0].Result != "Failed" {
+		t.Errorf("Expected previous attempt to be migrated, but found %+v", p.Scenarios[0].PreviousAttempts)
+	}
+}
+
+func TestReadProbeAudit_WithNewerSchema_ShouldReturnError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "probr-audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if _, err := ReadProbeAudit(writeTestFile(t, dir, "probe.json", `{"SchemaVersion": 99}`)); err == nil {
+		t.Errorf("Expected ReadProbeAudit() to return an error for an unsupported schema version")
+	}
+}
+
+func TestMergeSummaries_WithLegacySummary_ShouldOrderProbes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "probr-audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeTestFile(t, dir, filepath.Join("audit", "legacy_probe.json"), legacyAudit)
+	merged, err := MergeSummaries(writeTestFile(t, dir, "summary.json", legacySummaryFile))
+	if err != nil {
+		t.Fatalf("MergeSummaries() returned unexpected error: %v", err)
+	}
+	if len(merged.Probes) != 2 || len(merged.Probes["legacy_probe"].Scenarios) != 3 {
+		t.Fatalf("Expected legacy summary and audit to be merged, but found %+v", merged.Probes)
+	}
+
+	summary, err := readSummary(writeTestFile(t, dir, "merged.json", string(merged.summary())))
+	if err != nil {
+		t.Fatalf("readSummary() returned unexpected error: %v", err)
+	}
+	if summary.Probes[0].Name != "legacy_probe" || summary.Probes[1].Name != "z_probe" {
+		t.Errorf("Expected probes to be ordered by name, but found %s, %s", summary.Probes[0].Name, summary.Probes[1].Name)
+	}
+}
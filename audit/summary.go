@@ -1,77 +1,183 @@
 package audit
 
 import (
-	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	sdk "github.com/citihub/probr-sdk"
 	"github.com/citihub/probr-sdk/config"
+	"github.com/citihub/probr-sdk/redact"
 	"github.com/citihub/probr-sdk/utils"
 )
 
-// SummaryState is a stateful object intended to hold all the high-level info about a probe execution
+// SummaryState is a stateful object intended to hold all the high-level info about a probe execution.
+// Its methods, and those of the probes and scenarios it holds, are safe for concurrent use. Fields should only be read
+// directly once probes have stopped running; use Snapshot to inspect progress while they are still running.
 type SummaryState struct {
-	Meta           map[string]interface{}
-	Status         string
-	ProbesPassed   int
-	ProbesFailed   int
-	ProbesSkipped  int
-	Probes         map[string]*Probe
-	WriteDirectory string
+	RunID                  string // See sdk.NewRunID
+	Meta                   map[string]interface{}
+	Status                 string
+	ProbesPassed           int
+	ProbesFailed           int
+	ProbesSkipped          int
+	ProbesDependencyFailed int // Also included in ProbesSkipped
+	ProbesRetried          int // Probes that passed only after retrying scenarios; also included in ProbesPassed
+	Probes                 map[string]*Probe
+	WriteDirectory         string
+	Timing                 // Spans every completed probe
+
+	lock     *sync.RWMutex     // Shared with the audit of each probe
+	controls *ControlCatalogue // Used to roll up scenario results by control, see Compliance
 }
 
 // SummaryState is a stateful object intended to hold all the high-level info about a probe execution
 type limitedSummaryState struct {
-	Meta           map[string]interface{}
-	Status         string
-	ProbesPassed   int
-	ProbesFailed   int
-	ProbesSkipped  int
-	Probes         map[string]*limitedProbe
-	WriteDirectory string
+	SchemaVersion          int
+	RunID                  string
+	Meta                   map[string]interface{}
+	Status                 string
+	ProbesPassed           int
+	ProbesFailed           int
+	ProbesSkipped          int
+	ProbesDependencyFailed int
+	ProbesRetried          int
+	Probes                 []*limitedProbe // Ordered by name
+	Compliance             *Compliance     `json:",omitempty"` // Set if a control catalogue is configured
+	WriteDirectory         string
+	Timing
 }
 
 // NewSummaryState creates a new SummaryState with default values
 func NewSummaryState(packName string) (state SummaryState) {
 	writeDirectory := filepath.Join(sdk.GlobalConfig.OutputDir(), packName)
 	state = SummaryState{
+		RunID:          sdk.GlobalConfig.RunID,
 		Probes:         make(map[string]*Probe),
 		Meta:           make(map[string]interface{}),
 		WriteDirectory: writeDirectory,
+		lock:           &sync.RWMutex{},
+		controls:       configuredControls(),
 	}
 	return
 }
 
-// TODO: Marshal json, unmarshal into limited obj, then marshal & write/print
-
 // PrintSummary will print the current object state, formatted to JSON
 func (s *SummaryState) PrintSummary() {
 	log.Printf("Summary: %s", s.summary()) // Summary output should not be handled by log levels
 }
 
-// WriteSummary will write the summary to the audit directory
+// WriteSummary will write the summary and a SARIF log of failed scenarios to the audit directory.
+// Controls in the control catalogue that no scenario covers are logged as a warning.
+// The summary of the run of this process is also written to its output directory, see writeRunDirectory,
+// followed by a signed bundle of the run directory if a BundleKeyFile is configured, see WriteBundle.
+// The run directory is then recorded as the latest run; see sdk.GlobalOpts.SetLatest.
 func (s *SummaryState) WriteSummary() {
 	path := filepath.Join(config.Vars.GetWriteDirectory(), "summary.json")
 	if utils.WriteAllowed(path) {
 		ioutil.WriteFile(path, s.summary(), 0755)
 	}
+	if c := s.Compliance(); c != nil && c.ControlsNotCovered > 0 {
+		var uncovered []string
+		for _, control := range c.Controls {
+			if control.Result == ControlNotCovered {
+				uncovered = append(uncovered, fmt.Sprintf("%s %s", control.Framework, control.ID))
+			}
+		}
+		log.Printf("[WARN] %d controls are not covered by any scenario: %s", len(uncovered), strings.Join(uncovered, ", "))
+	}
+	s.WriteSARIF()
+	if s.RunID == sdk.GlobalConfig.RunID { // Merged summaries belong to the runs they were merged from
+		dir := sdk.GlobalConfig.OutputDir()
+		if err := s.writeRunDirectory(dir); err != nil {
+			log.Printf("[ERROR] Run directory was not written: %v", err)
+			return
+		}
+		WriteBundle(dir)
+		if err := sdk.GlobalConfig.SetLatest(); err != nil {
+			log.Printf("[ERROR] Could not record the latest run: %v", err)
+		}
+	}
+}
+
+// writeRunDirectory writes the summary, the audit of each probe in it, the SARIF log and the config to dir,
+// alongside the cucumber results written there by the probe engine, so that the directory is a complete record of
+// the run that later runs do not overwrite. The probe paths in the summary refer to the audits in dir.
+func (s *SummaryState) writeRunDirectory(dir string) error {
+	run := s.Snapshot()
+	auditDir := filepath.Join(dir, "audit")
+	if err := os.MkdirAll(auditDir, 0755); err != nil {
+		return err
+	}
+	for _, p := range run.Probes {
+		if p.Path == "" {
+			continue
+		}
+		p.Path = filepath.Join(auditDir, filepath.Base(p.Path))
+		if data := p.auditJSON(); data != nil {
+			if err := ioutil.WriteFile(p.Path, data, 0755); err != nil {
+				return err
+			}
+		}
+	}
+	files := map[string][]byte{
+		"summary.json": run.summary(),
+		SARIFFileName:  run.SARIF(dir),
+	}
+	if cfg, err := ioutil.ReadFile(filepath.Join(config.Vars.GetWriteDirectory(), "config.json")); err == nil {
+		files["config.json"] = cfg
+	}
+	for name, data := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), data, 0755); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (s *SummaryState) summary() []byte {
-	var limitedObj limitedSummaryState
-	fullJSON := utils.JSON(s)
-	err := json.Unmarshal(fullJSON, &limitedObj)
-	if err != nil {
-		log.Fatalf("[ERROR] Failed to parse summary into JSON: %s", err)
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	limitedObj := limitedSummaryState{
+		SchemaVersion:          SchemaVersion,
+		RunID:                  s.RunID,
+		Meta:                   s.Meta,
+		Status:                 s.Status,
+		ProbesPassed:           s.ProbesPassed,
+		ProbesFailed:           s.ProbesFailed,
+		ProbesSkipped:          s.ProbesSkipped,
+		ProbesDependencyFailed: s.ProbesDependencyFailed,
+		ProbesRetried:          s.ProbesRetried,
+		WriteDirectory:         s.WriteDirectory,
+		Compliance:             s.compliance(),
+		Timing:                 s.Timing,
+	}
+	for _, name := range s.probeNames() {
+		limitedObj.Probes = append(limitedObj.Probes, s.Probes[name].limited(name))
 	}
 	return utils.JSON(limitedObj)
 }
 
+// probeNames returns the names of the probes in the summary, in order
+func (s *SummaryState) probeNames() []string {
+	var names []string
+	for name := range s.Probes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 // SetProbrStatus evaluates the current SummaryState state to set the Status
 func (s *SummaryState) SetProbrStatus() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
 	attempted := (len(s.Probes) - s.ProbesSkipped)
 	succeeded := (attempted - s.ProbesFailed)
 	s.Status = fmt.Sprintf("Complete - %d/%d Succeeded (%d Skipped)", succeeded, attempted, s.ProbesSkipped)
@@ -79,31 +185,223 @@ func (s *SummaryState) SetProbrStatus() {
 
 // LogProbeMeta accepts a test name with a key and value to insert to the meta logs for that test. Overwrites key if already present.
 func (s *SummaryState) LogProbeMeta(name string, key string, value interface{}) {
-	probe := s.GetProbeLog(name)
-	probe.Meta[key] = value
-	s.Probes[name] = probe
-	s.Probes[name].name = name // probe must be able to access its own name, but it is not publicly printed
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.probeLog(name).Meta[key] = value
+}
+
+// LogMeta inserts a key and value to the meta logs of the summary. Overwrites key if already present.
+func (s *SummaryState) LogMeta(key string, value interface{}) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.Meta[key] = value
+}
+
+// LogProbeResult sets the result of the named probe, such as "Excluded" for a probe that will not be run
+func (s *SummaryState) LogProbeResult(name string, result string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.probeLog(name).Result = result
+}
+
+// LogProbeError records an error that prevented the named probe from completing, such as a timeout
+func (s *SummaryState) LogProbeError(name string, err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.probeLog(name).Error = redact.String(err.Error())
+}
+
+// LogProbeDependencyFailed records that the named probe was not run because a probe it depends on did not succeed
+func (s *SummaryState) LogProbeDependencyFailed(name string, dependency string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	probe := s.probeLog(name)
+	probe.Result = "Dependency Failed"
+	probe.Meta["failed_dependency"] = dependency
+}
+
+// LogProbeStart records the time the named probe started running
+func (s *SummaryState) LogProbeStart(name string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.probeLog(name).start(time.Now())
 }
 
 // ProbeComplete takes an probe name and status then updates the summary & probe meta information
 func (s *SummaryState) ProbeComplete(name string) {
-	p := s.GetProbeLog(name)
+	s.lock.Lock()
+	p := s.probeLog(name)
+	if !p.StartTime.IsZero() {
+		p.end(time.Now())
+	}
 	s.completeProbe(p)
-	p.Write()
+	data := p.auditJSON()
+	s.lock.Unlock()
+	p.write(data)
+}
+
+// RestoreProbe completes the named probe using the audit written to auditPath by an earlier run, so that a resumed
+// run includes its results. If no audit was written, e.g. because no scenarios were executed, the probe is completed without one.
+func (s *SummaryState) RestoreProbe(name string, auditPath string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	p := s.probeLog(name)
+	data, err := ioutil.ReadFile(auditPath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if len(data) > 0 {
+		if err := p.readAudit(data); err != nil {
+			return fmt.Errorf("could not parse audit '%s': %v", auditPath, err)
+		}
+	}
+	p.Meta["resumed_from"] = auditPath
+	s.completeProbe(p)
+	return nil
+}
+
+// RemoveProbe removes the named probe from the summary, e.g. because it is run by another shard
+func (s *SummaryState) RemoveProbe(name string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.Probes, name)
+}
+
+// MergeSummaries combines the summary.json files written by separate runs, such as the shards of a pack,
+// into a single SummaryState. The audit of each probe is read from the path recorded in the summary or,
+// if the files have been moved since, from the "audit" directory alongside the summary.
+// If a probe is found in more than one summary, the first is used.
+func MergeSummaries(summaryPaths ...string) (*SummaryState, error) {
+	merged := &SummaryState{
+		Probes:   make(map[string]*Probe),
+		Meta:     make(map[string]interface{}),
+		lock:     &sync.RWMutex{},
+		controls: configuredControls(),
+	}
+	for _, path := range summaryPaths {
+		summary, err := readSummary(path)
+		if err != nil {
+			return nil, err
+		}
+		if merged.WriteDirectory == "" {
+			merged.WriteDirectory = summary.WriteDirectory
+		}
+		if merged.RunID == "" {
+			merged.RunID = summary.RunID // Shards of a run share its ID
+		}
+		for key, value := range summary.Meta {
+			if _, exists := merged.Meta[key]; !exists {
+				merged.Meta[key] = value
+			}
+		}
+		for _, limited := range summary.Probes {
+			if _, exists := merged.Probes[limited.Name]; exists {
+				log.Printf("[WARN] Probe '%s' was found in more than one summary; the result from '%s' will be ignored", limited.Name, path)
+				continue
+			}
+			p, err := mergedProbe(limited, filepath.Dir(path), merged.lock)
+			if err != nil {
+				return nil, err
+			}
+			merged.Probes[limited.Name] = p
+			merged.completeProbe(p)
+		}
+	}
+	merged.Meta["merged_from"] = summaryPaths
+	merged.SetProbrStatus()
+	return merged, nil
+}
+
+// mergedProbe reads the audit of the named probe, or recreates it from the summary if no audit was written
+func mergedProbe(limited *limitedProbe, summaryDir string, lock *sync.RWMutex) (*Probe, error) {
+	p := &Probe{
+		lock:   lock,
+		name:   limited.Name,
+		Meta:   limited.Meta,
+		Path:   limited.Path,
+		Result: limited.Result,
+		Error:  limited.Error,
+		Timing: limited.Timing,
+	}
+	if p.Meta == nil {
+		p.Meta = make(map[string]interface{})
+	}
+	if limited.Path == "" {
+		return p, nil
+	}
+	data, err := ioutil.ReadFile(limited.Path)
+	if os.IsNotExist(err) {
+		data, err = ioutil.ReadFile(filepath.Join(summaryDir, "audit", filepath.Base(limited.Path)))
+	}
+	if os.IsNotExist(err) {
+		return p, nil // No audit is written for probes without scenarios
+	} else if err != nil {
+		return nil, err
+	}
+	if len(data) > 0 {
+		if err := p.readAudit(data); err != nil {
+			return nil, fmt.Errorf("could not parse audit for probe '%s': %v", limited.Name, err)
+		}
+	}
+	return p, nil
 }
 
 // GetProbeLog initializes or returns existing log probe for the provided test name
 func (s *SummaryState) GetProbeLog(name string) *Probe {
 	// If SummaryState is improperly initialized, a dereference error will occur below.
 	// log.Printf("[DEBUG] GetProbeLog(%s) called by: %s->%s->%s", name, utils.CallerName(1), utils.CallerName(2), utils.CallerName(3))
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.probeLog(name)
+}
+
+// probeLog is GetProbeLog for callers that already hold the lock
+func (s *SummaryState) probeLog(name string) *Probe {
 	if s.Probes[name] == nil {
 		s.initProbe(name)
 	}
 	return s.Probes[name]
 }
 
+// Snapshot returns a copy of the summary, including the audit of each probe, that is safe to inspect while probes
+// are still running. The copy is not updated as probes progress, and changes to it do not affect the summary.
+// Step payloads are shared with the summary, and should not be modified.
+func (s *SummaryState) Snapshot() *SummaryState {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	snapshot := *s
+	snapshot.lock = &sync.RWMutex{}
+	snapshot.Meta = copyMeta(s.Meta)
+	snapshot.Probes = make(map[string]*Probe, len(s.Probes))
+	for name, p := range s.Probes {
+		snapshot.Probes[name] = p.snapshot(snapshot.lock)
+	}
+	return &snapshot
+}
+
+// ProbeSnapshot returns a copy of the audit of the named probe, as Snapshot does, or nil if the summary does not include it
+func (s *SummaryState) ProbeSnapshot(name string) *Probe {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	p, found := s.Probes[name]
+	if !found {
+		return nil
+	}
+	return p.snapshot(&sync.RWMutex{})
+}
+
+func copyMeta(meta map[string]interface{}) map[string]interface{} {
+	c := make(map[string]interface{}, len(meta))
+	for key, value := range meta {
+		c[key] = value
+	}
+	return c
+}
+
 // LogPodName adds pod names to a list for user's debugging purposes
 func (s *SummaryState) LogPodName(n string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
 	podNames := s.Meta["names of pods created"].([]string)
 	podNames = append(podNames, n)
 
@@ -112,6 +410,7 @@ func (s *SummaryState) LogPodName(n string) {
 
 func (s *SummaryState) initProbe(n string) {
 	s.Probes[n] = &Probe{
+		lock: s.lock,
 		name: n,
 		Meta: make(map[string]interface{}),
 		Path: filepath.Join(config.Vars.AuditDir(), (n + ".json")),
@@ -119,14 +418,27 @@ func (s *SummaryState) initProbe(n string) {
 }
 
 func (s *SummaryState) completeProbe(e *Probe) {
+	e.closed = true
 	e.countResults()
-	if e.Result == "Excluded" {
+	s.include(e.Timing)
+	if e.Error != "" {
+		e.Result = "Error"
+		s.ProbesFailed = s.ProbesFailed + 1
+	} else if e.Result == "Dependency Failed" {
+		e.Meta["audit_path"] = ""
+		s.ProbesSkipped = s.ProbesSkipped + 1
+		s.ProbesDependencyFailed = s.ProbesDependencyFailed + 1
+	} else if e.Result == "Excluded" {
 		e.Meta["audit_path"] = ""
 		s.ProbesSkipped = s.ProbesSkipped + 1
 	} else if len(e.Scenarios) < 1 {
 		e.Result = "No Scenarios Executed"
 		e.Meta["audit_path"] = ""
 		s.ProbesSkipped = s.ProbesSkipped + 1
+	} else if e.ScenariosFailed < 1 && e.ScenariosRetried > 0 {
+		e.Result = "Success After Retry"
+		s.ProbesPassed = s.ProbesPassed + 1
+		s.ProbesRetried = s.ProbesRetried + 1
 	} else if e.ScenariosFailed < 1 {
 		e.Result = "Success"
 		s.ProbesPassed = s.ProbesPassed + 1
@@ -0,0 +1,185 @@
+package audit
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	sdk "github.com/citihub/probr-sdk"
+	"github.com/citihub/probr-sdk/config"
+	"github.com/citihub/probr-sdk/events"
+	"github.com/cucumber/messages-go/v10"
+)
+
+func TestSummaryState_WithConcurrentProbes_ShouldAuditEveryStep(t *testing.T) {
+	dir, err := ioutil.TempDir("", "probr-audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Vars.WriteDirectory = dir
+	defer func() {
+		os.RemoveAll(dir) // Delete test data after tests
+		config.Vars.WriteDirectory = ""
+	}()
+
+	summary := NewSummaryState("test_pack")
+	// Subscribers are called as steps are audited, and must be able to inspect the audit without deadlocking
+	unsubscribe := events.Subscribe(func(e events.Event) {
+		if e.Probe != "" {
+			summary.ProbeSnapshot(e.Probe)
+		}
+	})
+	defer unsubscribe()
+
+	const probes, scenarios, steps = 8, 4, 3
+	done := make(chan struct{})
+	snapshots := make(chan int)
+	go func() {
+		count := 0
+		for {
+			select {
+			case <-done:
+				snapshots <- count
+				return
+			default:
+				s := summary.Snapshot()
+				for _, p := range s.Probes {
+					for _, scenario := range p.Scenarios {
+						_ = len(scenario.Steps)
+					}
+				}
+				summary.summary()
+				count++
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < probes; i++ {
+		name := fmt.Sprintf("probe_%d", i)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			summary.LogProbeStart(name)
+			summary.LogProbeMeta(name, "group", "test_pack")
+			var scenarioWG sync.WaitGroup
+			for j := 0; j < scenarios; j++ {
+				scenarioWG.Add(1)
+				go func(j int) {
+					defer scenarioWG.Done()
+					scenario := summary.GetProbeLog(name).InitializeAuditor(fmt.Sprintf("scenario %d", j), []*messages.Pickle_PickleTag{})
+					for k := 0; k < steps; k++ {
+						var err error
+						if j == 0 && k == steps-1 && name == "probe_0" {
+							err = errors.New("failed")
+						}
+						scenario.AuditScenarioStep(fmt.Sprintf("step %d", k), "", k, err)
+					}
+				}(j)
+			}
+			scenarioWG.Wait()
+			summary.ProbeComplete(name)
+		}()
+	}
+	wg.Wait()
+	close(done)
+	if <-snapshots == 0 {
+		t.Errorf("Expected snapshots to be taken while probes were running")
+	}
+	summary.SetProbrStatus()
+
+	if summary.ProbesPassed != probes-1 || summary.ProbesFailed != 1 {
+		t.Errorf("Expected %d probes to pass and 1 to fail, but found %d passed and %d failed", probes-1, summary.ProbesPassed, summary.ProbesFailed)
+	}
+	for name, p := range summary.Probes {
+		if len(p.Scenarios) != scenarios {
+			t.Errorf("Expected probe '%s' to audit %d scenarios, but found %d", name, scenarios, len(p.Scenarios))
+		}
+		for _, scenario := range p.Scenarios {
+			if len(scenario.Steps) != steps {
+				t.Errorf("Expected scenario '%s' of probe '%s' to audit %d steps, but found %d", scenario.Name, name, steps, len(scenario.Steps))
+			}
+		}
+	}
+}
+
+func TestSummaryState_Snapshot_ShouldNotChangeWithSummary(t *testing.T) {
+	dir, err := ioutil.TempDir("", "probr-audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Vars.WriteDirectory = dir
+	defer func() {
+		os.RemoveAll(dir) // Delete test data after tests
+		config.Vars.WriteDirectory = ""
+	}()
+
+	summary := NewSummaryState("test_pack")
+	scenario := summary.GetProbeLog("probe").InitializeAuditor("scenario", []*messages.Pickle_PickleTag{})
+	scenario.AuditScenarioStep("first step", "", nil, nil)
+
+	snapshot := summary.Snapshot()
+	probeSnapshot := summary.ProbeSnapshot("probe")
+	scenario.AuditScenarioStep("second step", "", nil, errors.New("failed"))
+	summary.LogProbeMeta("probe", "key", "value")
+	snapshot.GetProbeLog("probe").Scenarios[0].AuditScenarioStep("snapshot step", "", nil, nil)
+
+	if len(scenario.Steps) != 2 || scenario.Result != "Failed" {
+		t.Errorf("Expected changes to the snapshot not to affect the summary, but found %d steps with result '%s'", len(scenario.Steps), scenario.Result)
+	}
+	if steps := probeSnapshot.Scenarios[0].Steps; len(steps) != 1 || probeSnapshot.Scenarios[0].Result != "Passed" {
+		t.Errorf("Expected the probe snapshot not to change with the summary, but found %d steps", len(steps))
+	}
+	if _, found := snapshot.Probes["probe"].Meta["key"]; found {
+		t.Errorf("Expected the snapshot meta not to change with the summary")
+	}
+	if summary.ProbeSnapshot("missing") != nil {
+		t.Errorf("Expected no snapshot for a probe that is not in the summary")
+	}
+}
+
+func TestSummaryState_WriteSummary_ShouldRecordRunDirectoryAsLatest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "probr-audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	installDir := sdk.GlobalConfig.InstallDir
+	config.Vars.WriteDirectory = filepath.Join(dir, "write")
+	sdk.GlobalConfig.InstallDir = filepath.Join(dir, "install")
+	defer func() {
+		os.RemoveAll(dir) // Delete test data after tests
+		config.Vars.WriteDirectory = ""
+		sdk.GlobalConfig.InstallDir = installDir
+	}()
+
+	summary := NewSummaryState("test_pack")
+	scenario := summary.GetProbeLog("probe").InitializeAuditor("scenario", []*messages.Pickle_PickleTag{})
+	scenario.AuditScenarioStep("a cluster exists", "", nil, nil)
+	scenario.AuditScenarioStep("a pod is denied", "", nil, errors.New("pod was created"))
+	summary.ProbeComplete("probe")
+	summary.LogProbeResult("excluded", "Excluded")
+	summary.ProbeComplete("excluded")
+	summary.WriteSummary()
+
+	latest, err := sdk.GlobalConfig.LatestOutputDir()
+	if err != nil || latest != sdk.GlobalConfig.OutputDir() {
+		t.Fatalf("LatestOutputDir() = '%s', %v, Expected: '%s'", latest, err, sdk.GlobalConfig.OutputDir())
+	}
+	for _, name := range []string{"summary.json", SARIFFileName, filepath.Join("audit", "probe.json")} {
+		if _, err := os.Stat(filepath.Join(latest, name)); err != nil {
+			t.Errorf("Expected '%s' to be written to the run directory: %v", name, err)
+		}
+	}
+	run, err := MergeSummaries(filepath.Join(latest, "summary.json"))
+	if err != nil {
+		t.Fatalf("MergeSummaries() returned unexpected error: %v", err)
+	}
+	p := run.Probes["probe"]
+	if p == nil || filepath.Dir(p.Path) != filepath.Join(latest, "audit") || len(p.Scenarios) != 1 || p.Result != "Failed" {
+		t.Errorf("Expected the run directory's summary to refer to the audit in the run directory, but found %+v", p)
+	}
+}
@@ -0,0 +1,41 @@
+package audit
+
+import "time"
+
+// Timing records when a probe, scenario or step started and ended
+type Timing struct {
+	StartTime time.Time
+	EndTime   time.Time
+	Duration  time.Duration
+}
+
+// start resets the timing to begin at the given time
+func (t *Timing) start(now time.Time) {
+	t.StartTime = now
+	t.EndTime = time.Time{}
+	t.Duration = 0
+}
+
+// end sets the end time, and the duration if the start time is known
+func (t *Timing) end(now time.Time) {
+	t.EndTime = now
+	if !t.StartTime.IsZero() {
+		t.Duration = now.Sub(t.StartTime)
+	}
+}
+
+// include extends the timing to cover other, so that the timing of a summary spans all of its probes
+func (t *Timing) include(other Timing) {
+	if other.StartTime.IsZero() {
+		return
+	}
+	if t.StartTime.IsZero() || other.StartTime.Before(t.StartTime) {
+		t.StartTime = other.StartTime
+	}
+	if other.EndTime.After(t.EndTime) {
+		t.EndTime = other.EndTime
+	}
+	if !t.EndTime.IsZero() {
+		t.Duration = t.EndTime.Sub(t.StartTime)
+	}
+}
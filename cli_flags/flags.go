@@ -4,7 +4,11 @@ import (
 	"flag"
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/citihub/probr-sdk/audit"
 	"github.com/citihub/probr-sdk/config"
 	"github.com/citihub/probr-sdk/logging"
 	"github.com/citihub/probr-sdk/utils"
@@ -12,6 +16,7 @@ import (
 
 type stringHandlerFunc func(value *string)
 type boolHandlerFunc func(value *bool)
+type stringSliceHandlerFunc func(value *[]string)
 
 // Flag allows for different value types to be handled
 type Flag interface {
@@ -37,6 +42,25 @@ type BoolFlag struct {
 	Value   *bool
 }
 
+// StringSliceFlag holds the user-provided values for a flag that may be repeated, and the function to be run within executeHandler
+type StringSliceFlag struct {
+	Name    string
+	Handler stringSliceHandlerFunc
+	Value   *[]string
+}
+
+// stringSliceValue implements flag.Value, appending the value each time the flag is provided
+type stringSliceValue []string
+
+func (v *stringSliceValue) String() string {
+	return strings.Join(*v, ",")
+}
+
+func (v *stringSliceValue) Set(value string) error {
+	*v = append(*v, value)
+	return nil
+}
+
 func (f StringFlag) executeHandler() {
 	f.Handler(f.Value)
 }
@@ -45,6 +69,10 @@ func (f BoolFlag) executeHandler() {
 	f.Handler(f.Value)
 }
 
+func (f StringSliceFlag) executeHandler() {
+	f.Handler(f.Value)
+}
+
 // ExecuteHandlers executes the logic for any flags that are provided via `./probr (--<FLAG>)`
 func (flags *Flags) ExecuteHandlers() {
 	flag.Parse()
@@ -74,6 +102,17 @@ func (flags *Flags) NewBoolFlag(name string, usage string, handler boolHandlerFu
 	flags.PreParsedFlags = append(flags.PreParsedFlags, f)
 }
 
+// NewStringSliceFlag creates a new flag that may be provided more than once, collecting each value
+func (flags *Flags) NewStringSliceFlag(name string, usage string, handler stringSliceHandlerFunc) {
+	f := StringSliceFlag{
+		Name:    name,
+		Handler: handler,
+		Value:   new([]string),
+	}
+	flag.Var((*stringSliceValue)(f.Value), name, usage)
+	flags.PreParsedFlags = append(flags.PreParsedFlags, f)
+}
+
 // VarsFileHandler initializes configuration with VarsFile overriding env vars & defaults
 func VarsFileHandler(v *string) {
 	value := *v
@@ -113,11 +152,14 @@ func LoglevelHandler(v *string) {
 	}
 }
 
+// resultsFormatOptions are the godog formats that results may be written in
+var resultsFormatOptions = []string{"cucumber", "events", "junit", "pretty", "progress"}
+
 // ResultsformatHandler parses a flag and sets the godog output type
 func ResultsformatHandler(v *string) {
 	value := *v
 	if len(value) > 0 {
-		options := []string{"cucumber", "events", "junit", "pretty", "progress"}
+		options := resultsFormatOptions
 		_, found := utils.FindString(options, value)
 		if !found {
 			log.Fatalf("[ERROR] Unknown resultsformat specified: '%s'. Must be one of %v", value, options)
@@ -130,6 +172,105 @@ func ResultsformatHandler(v *string) {
 	}
 }
 
+// ResultsFormatsHandler parses a repeatable flag and sets each godog format that results will be written in.
+// Each value is a format, optionally followed by ":stdout" to write to stdout rather than to a file in the cucumber directory.
+// godog writes a single format per run, so each format after the first runs the probe's scenarios again; only the
+// first run is audited and determines the probe's result.
+func ResultsFormatsHandler(v *[]string) {
+	if len(*v) == 0 {
+		return
+	}
+	for _, value := range *v {
+		format := strings.SplitN(value, ":", 2)[0]
+		if _, found := utils.FindString(resultsFormatOptions, format); !found {
+			log.Fatalf("[ERROR] Unknown resultsformat specified: '%s'. Must be one of %v", format, resultsFormatOptions)
+		}
+	}
+	config.Vars.ResultsFormats = *v
+	log.Printf("[INFO] Results will be written in formats %v", *v)
+}
+
+// MaxConcurrentProbesHandler validates and sets the number of probes that may be executed at the same time
+func MaxConcurrentProbesHandler(v *string) {
+	value := *v
+	if len(value) > 0 {
+		i, err := strconv.Atoi(value)
+		if err != nil || i < 1 {
+			log.Fatalf("[ERROR] Invalid value specified for max concurrent probes: '%s'. Must be a positive integer", value)
+		}
+		config.Vars.MaxConcurrentProbes = value
+		log.Printf("[NOTICE] Max concurrent probes has been overridden via command line")
+	}
+}
+
+// DryRunHandler validates the format of the execution plan to be printed instead of running probes
+func DryRunHandler(v *string) {
+	value := *v
+	if len(value) > 0 {
+		options := []string{"table", "json"}
+		_, found := utils.FindString(options, value)
+		if !found {
+			log.Fatalf("[ERROR] Unknown dryrun format specified: '%s'. Must be one of %v", value, options)
+		}
+		config.Vars.DryRun = value
+		log.Printf("[NOTICE] Dry run requested; probes will be listed but not executed")
+	}
+}
+
+// StaticOverrideDirHandler sets a local directory to read feature files and other static files from,
+// in preference to those bundled with the service pack
+func StaticOverrideDirHandler(v *string) {
+	value := *v
+	if len(value) > 0 {
+		config.Vars.StaticOverrideDir = value
+		utils.SetStaticOverrideDir(value)
+		log.Printf("[NOTICE] Static files will be read from '%s' where present", value)
+	}
+}
+
+// ResumeHandler sets probes completed by an interrupted run to be skipped, with their results carried into this run
+func ResumeHandler(v *bool) {
+	if *v {
+		config.Vars.Resume = true
+		log.Printf("[NOTICE] Probes completed by the previous run will not be run again")
+	}
+}
+
+// ShardHandler parses a flag in the form "<index>/<total>" and sets the shard of probes to be run, with index starting at 0
+func ShardHandler(v *string) {
+	value := *v
+	if len(value) > 0 {
+		parts := strings.Split(value, "/")
+		if len(parts) != 2 {
+			log.Fatalf("[ERROR] Unknown shard specified: '%s'. Must be in the form <index>/<total>, e.g. 0/4", value)
+		}
+		config.Vars.ShardIndex, config.Vars.ShardTotal = parts[0], parts[1]
+		if index, total := config.Vars.GetShard(); total == 1 && parts[1] != "1" {
+			log.Fatalf("[ERROR] Invalid shard specified: '%s'", value)
+		} else {
+			log.Printf("[NOTICE] Running shard %d of %d", index, total)
+		}
+	}
+}
+
+// VerifyBundleHandler verifies the signed bundle at the provided path using the configured BundlePublicKeyFile,
+// then exits with a non-zero status if the bundle has been modified since it was signed
+func VerifyBundleHandler(v *string) {
+	value := *v
+	if len(value) > 0 {
+		key, err := audit.ReadVerifyingKey(config.Vars.BundlePublicKeyFile)
+		if err != nil {
+			log.Fatalf("[ERROR] Could not read bundle public key: %v", err)
+		}
+		manifest, err := audit.VerifyBundle(value, key)
+		if err != nil {
+			log.Fatalf("[ERROR] Bundle verification failed: %v", err)
+		}
+		log.Printf("Bundle '%s' is valid: %d files signed at %s", value, len(manifest.Files), manifest.Created.Format(time.RFC3339)) // Result should not be handled by log levels
+		os.Exit(0)
+	}
+}
+
 // TagsHandler parses a flag and sets the godog/cucumber tags
 func TagsHandler(v *string) {
 	value := *v
@@ -83,3 +83,23 @@ func TestFlags_NewStringFlag(t *testing.T) {
 		})
 	}
 }
+
+func TestFlags_NewStringSliceFlag(t *testing.T) {
+	var testFuncOutput []string
+
+	name := "testSliceFunc"
+	expected := []string{"pretty:stdout", "junit", "cucumber"}
+	for _, value := range expected {
+		os.Args = append(os.Args, fmt.Sprintf("-%s=%s", name, value))
+	}
+
+	var flags Flags
+	flags.NewStringSliceFlag(name, "testSliceFunc usage", func(value *[]string) {
+		testFuncOutput = *value
+	})
+	flags.ExecuteHandlers()
+
+	if fmt.Sprint(testFuncOutput) != fmt.Sprint(expected) {
+		t.Errorf("Expected each value of repeated flag '%s' to be collected as %v, but found %v", name, expected, testFuncOutput)
+	}
+}
@@ -6,7 +6,9 @@ import (
 	"os"
 	"strings"
 
+	"github.com/citihub/probr-sdk/audit"
 	"github.com/citihub/probr-sdk/config"
+	"github.com/citihub/probr-sdk/retention"
 )
 
 // HandleRequestForRequiredVars will execute the logic for `./probr show-requirements (<PACK>)`
@@ -58,3 +60,49 @@ func HandlePackOption() {
 		log.Printf("[DEBUG] Args after 'run %s': %s", config.Vars.Meta.RunOnly, os.Args)
 	}
 }
+
+// HandleDiffOption will execute the logic for `./probr diff <OLD-RUN> <NEW-RUN> (json)`,
+// where each run is a run ID, "latest", a run directory, or a summary.json file; see audit.DiffRuns
+func HandleDiffOption() {
+	if os.Args[1] == "diff" {
+		log.Printf("[INFO] CLI option 'diff' was found")
+		if len(os.Args) < 4 || (len(os.Args) > 4 && os.Args[4] != "json") {
+			log.Printf("[ERROR] Expected two runs to compare.\n\nUsage: ./probr diff <OLD-RUN> <NEW-RUN> (json)\n\n")
+			os.Exit(2)
+		}
+		diff, err := audit.DiffRuns(os.Args[2], os.Args[3])
+		if err != nil {
+			log.Printf("[ERROR] Could not compare runs: %v", err)
+			os.Exit(2)
+		}
+		if len(os.Args) > 4 {
+			fmt.Printf("%s\n", diff.JSON())
+		} else {
+			fmt.Print(diff.String())
+		}
+		os.Exit(0) // Never run probr if 'diff' is called
+	}
+}
+
+// HandlePruneOption will execute the logic for `./probr prune (dry-run)`, applying the configured retention policy
+// to the run directories in the output directory. The directories of the current run are never deleted.
+func HandlePruneOption() {
+	if os.Args[1] == "prune" {
+		log.Printf("[INFO] CLI option 'prune' was found")
+		dryRun := len(os.Args) > 2 && os.Args[2] == "dry-run"
+		runs, err := retention.Prune(retention.OutputRoot(), retention.ConfiguredPolicy(), dryRun)
+		if err != nil {
+			log.Printf("[ERROR] Could not prune run directories: %v", err)
+			os.Exit(2)
+		}
+		verb := "Deleted"
+		if dryRun {
+			verb = "Would delete"
+		}
+		for _, run := range runs {
+			fmt.Printf("%s %s (%s)\n", verb, run.Path, run.Reason)
+		}
+		fmt.Printf("%s %d run directories\n", verb, len(runs))
+		os.Exit(0) // Never run probr if 'prune' is called
+	}
+}
@@ -8,11 +8,14 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/briandowns/spinner"
 	"github.com/citihub/probr-sdk/logging"
+	"github.com/citihub/probr-sdk/redact"
 	"github.com/citihub/probr-sdk/utils"
 	"gopkg.in/yaml.v2"
 )
@@ -43,7 +46,9 @@ func Init(configPath string) error {
 	Vars = config
 	setFromEnvOrDefaults(&Vars) // Set any values not retrieved from file
 
-	SetLogFilter(Vars.LogLevel, os.Stderr) // Set the minimum log level obtained from Vars
+	Vars.setRedactionRules()
+	logging.SetLogFilter(Vars.LogLevel, os.Stderr) // Set the minimum log level obtained from Vars
+	utils.SetStaticOverrideDir(Vars.StaticOverrideDir)
 	log.Printf("[DEBUG] Config initialized by %s", utils.CallerName(1))
 
 	Vars.handleConfigFileExclusions()
@@ -92,9 +97,34 @@ func ValidateConfigPath(path string) error {
 	return nil
 }
 
-// LogConfigState will write the config file to the write directory
+// setRedactionRules applies the configured redaction rules to logs and audit payloads, in addition to the built-in rules.
+// Keys and patterns that can't be compiled are logged and ignored, so that the remaining rules still apply.
+func (ctx *VarOptions) setRedactionRules() {
+	keys := compilableRedactionRules("key", "(?i)", ctx.Redaction.Keys)
+	patterns := compilableRedactionRules("pattern", "", ctx.Redaction.Patterns)
+	rules, err := redact.NewRules(keys, patterns)
+	if err != nil {
+		log.Printf("[ERROR] %v; only the built-in redaction rules will be applied", err)
+		return
+	}
+	redact.SetRules(rules)
+}
+
+// compilableRedactionRules returns the expressions that compile as regular expressions once prefixed, logging the others
+func compilableRedactionRules(kind, prefix string, expressions []string) (valid []string) {
+	for _, e := range expressions {
+		if _, err := regexp.Compile(prefix + e); err != nil {
+			log.Printf("[ERROR] Redaction %s '%s' could not be compiled and will be ignored: %v", kind, e, err)
+			continue
+		}
+		valid = append(valid, e)
+	}
+	return
+}
+
+// LogConfigState will write the config file to the write directory, with any secrets masked
 func (ctx *VarOptions) LogConfigState() {
-	json, _ := json.MarshalIndent(Vars, "", "  ")
+	json, _ := json.MarshalIndent(redact.Struct(Vars), "", "  ")
 	log.Printf("[INFO] Config State: %s", json)
 	path := filepath.Join(ctx.GetWriteDirectory(), "config.json")
 	if ctx.WriteConfig == "true" && utils.WriteAllowed(path) {
@@ -114,6 +144,238 @@ func (ctx *VarOptions) Overwrite() bool {
 	return value
 }
 
+// GetMaxConcurrentProbes returns the number of probes that may be executed at the same time.
+// Any value that cannot be parsed, or is less than one, will result in probes being run serially.
+func (ctx *VarOptions) GetMaxConcurrentProbes() int {
+	if ctx.MaxConcurrentProbes == "" {
+		return 1
+	}
+	value, err := strconv.Atoi(ctx.MaxConcurrentProbes)
+	if err != nil || value < 1 {
+		log.Printf("[ERROR] Could not parse value '%s' for MaxConcurrentProbes; probes will be run serially", ctx.MaxConcurrentProbes)
+		return 1
+	}
+	return value
+}
+
+// GetProbeTimeout returns the maximum duration a single probe may run for. Zero means no limit.
+func (ctx *VarOptions) GetProbeTimeout() time.Duration {
+	return parseTimeout("ProbeTimeout", ctx.ProbeTimeout)
+}
+
+// GetRunTimeout returns the maximum duration all probes combined may run for. Zero means no limit.
+func (ctx *VarOptions) GetRunTimeout() time.Duration {
+	return parseTimeout("RunTimeout", ctx.RunTimeout)
+}
+
+// GetRetentionKeepRuns returns the number of most recent run directories to keep. Zero means no limit.
+func (ctx *VarOptions) GetRetentionKeepRuns() int {
+	if ctx.Retention.KeepRuns == "" {
+		return 0
+	}
+	value, err := strconv.Atoi(ctx.Retention.KeepRuns)
+	if err != nil || value < 1 {
+		log.Printf("[ERROR] Could not parse value '%s' for Retention.KeepRuns; runs will not be limited by number", ctx.Retention.KeepRuns)
+		return 0
+	}
+	return value
+}
+
+// GetRetentionMaxAge returns the age after which run directories are deleted. Zero means no limit.
+func (ctx *VarOptions) GetRetentionMaxAge() time.Duration {
+	if ctx.Retention.MaxAge == "" {
+		return 0
+	}
+	d, err := time.ParseDuration(ctx.Retention.MaxAge)
+	if err != nil || d <= 0 {
+		log.Printf("[ERROR] Could not parse value '%s' for Retention.MaxAge; runs will not be limited by age", ctx.Retention.MaxAge)
+		return 0
+	}
+	return d
+}
+
+// sizeUnits are the suffixes accepted by GetRetentionMaxTotalSize
+var sizeUnits = []struct {
+	suffix     string
+	multiplier int64
+}{
+	{"GB", 1 << 30},
+	{"MB", 1 << 20},
+	{"KB", 1 << 10},
+	{"B", 1},
+}
+
+// GetRetentionMaxTotalSize returns the combined size in bytes that run directories may occupy. Zero means no limit.
+func (ctx *VarOptions) GetRetentionMaxTotalSize() int64 {
+	value := strings.ToUpper(strings.TrimSpace(ctx.Retention.MaxTotalSize))
+	if value == "" {
+		return 0
+	}
+	multiplier := int64(1)
+	for _, unit := range sizeUnits {
+		if strings.HasSuffix(value, unit.suffix) {
+			value, multiplier = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix)), unit.multiplier
+			break
+		}
+	}
+	size, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || size < 1 {
+		log.Printf("[ERROR] Could not parse value '%s' for Retention.MaxTotalSize; runs will not be limited by size", ctx.Retention.MaxTotalSize)
+		return 0
+	}
+	return size * multiplier
+}
+
+// GetExitFailOn returns which failed scenarios fail the run: "any", "critical" or "none".
+// Any other value will result in any failed scenario failing the run.
+func (ctx *VarOptions) GetExitFailOn() string {
+	value := strings.ToLower(strings.TrimSpace(ctx.ExitPolicy.FailOn))
+	switch value {
+	case "any", "critical", "none":
+		return value
+	case "":
+		return "any"
+	}
+	log.Printf("[ERROR] Could not parse value '%s' for ExitPolicy.FailOn; any failed scenario will fail the run", ctx.ExitPolicy.FailOn)
+	return "any"
+}
+
+// GetExitMinPassRate returns the fraction, from 0 to 1, of passed and failed scenarios that must pass. Zero means no threshold.
+func (ctx *VarOptions) GetExitMinPassRate() float64 {
+	value := strings.TrimSuffix(strings.TrimSpace(ctx.ExitPolicy.MinPassRate), "%")
+	if value == "" {
+		return 0
+	}
+	percent, err := strconv.ParseFloat(value, 64)
+	if err != nil || percent < 0 || percent > 100 {
+		log.Printf("[ERROR] Could not parse value '%s' for ExitPolicy.MinPassRate; no pass rate threshold will be applied", ctx.ExitPolicy.MinPassRate)
+		return 0
+	}
+	return percent / 100
+}
+
+func parseTimeout(name, value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		log.Printf("[ERROR] Could not parse value '%s' for %s; no timeout will be applied", value, name)
+		return 0
+	}
+	return d
+}
+
+// GetRetry returns the retry options for a scenario in the named probe, where the scenario is identified by its tags.
+// Options on the scenario take precedence over those on the probe, which take precedence over the global options.
+// Probe and scenario options are only used if they set MaxAttempts.
+func (ctx *VarOptions) GetRetry(packName, probeName string, scenarioTags []string) Retry {
+	retry := ctx.Retry
+	for _, probe := range ctx.packProbes(packName) {
+		if probe.Name != probeName {
+			continue
+		}
+		if probe.Retry.MaxAttempts != "" {
+			retry = probe.Retry
+		}
+		for _, scenario := range probe.Scenarios {
+			tag := fmt.Sprintf("@probes/%s/%s/%s", packName, probe.Name, scenario.Name)
+			if _, found := utils.FindString(scenarioTags, tag); found && scenario.Retry.MaxAttempts != "" {
+				retry = scenario.Retry
+			}
+		}
+	}
+	return retry
+}
+
+func (ctx *VarOptions) packProbes(packName string) []Probe {
+	switch strings.ToLower(packName) { //TODO: logic specific to service packs should be handled outside of SDK
+	case "kubernetes":
+		return ctx.ServicePacks.Kubernetes.Probes
+	case "storage":
+		return ctx.ServicePacks.Storage.Probes
+	case "apim":
+		return ctx.ServicePacks.APIM.Probes
+	}
+	return nil
+}
+
+// GetMaxAttempts returns the number of times a scenario may be attempted, including the first attempt.
+// Any value that cannot be parsed, or is less than one, will result in scenarios not being retried.
+func (r Retry) GetMaxAttempts() int {
+	if r.MaxAttempts == "" {
+		return 1
+	}
+	value, err := strconv.Atoi(r.MaxAttempts)
+	if err != nil || value < 1 {
+		log.Printf("[ERROR] Could not parse value '%s' for Retry.MaxAttempts; scenarios will not be retried", r.MaxAttempts)
+		return 1
+	}
+	return value
+}
+
+// GetBackoff returns the duration to wait before the first retry of a scenario.
+func (r Retry) GetBackoff() time.Duration {
+	if r.Backoff == "" {
+		return 0
+	}
+	d, err := time.ParseDuration(r.Backoff)
+	if err != nil || d < 0 {
+		log.Printf("[ERROR] Could not parse value '%s' for Retry.Backoff; scenarios will be retried immediately", r.Backoff)
+		return 0
+	}
+	return d
+}
+
+// GetShard returns the index of the shard to be run, and the total number of shards that probes are split between.
+// Any value that cannot be parsed results in all probes being run.
+func (ctx *VarOptions) GetShard() (index int, total int) {
+	if ctx.ShardTotal == "" || ctx.ShardTotal == "1" {
+		return 0, 1
+	}
+	total, err := strconv.Atoi(ctx.ShardTotal)
+	if err != nil || total < 1 {
+		log.Printf("[ERROR] Could not parse value '%s' for ShardTotal; all probes will be run", ctx.ShardTotal)
+		return 0, 1
+	}
+	if ctx.ShardIndex != "" {
+		index, err = strconv.Atoi(ctx.ShardIndex)
+	}
+	if err != nil || index < 0 || index >= total {
+		log.Printf("[ERROR] ShardIndex '%s' must be between 0 and %d; all probes will be run", ctx.ShardIndex, total-1)
+		return 0, 1
+	}
+	return index, total
+}
+
+// GetShardDurations returns the durations of previous runs of each probe, used to balance shards.
+// Nil is returned if ShardDurationsFile is not set or cannot be read.
+func (ctx *VarOptions) GetShardDurations() map[string]time.Duration {
+	if ctx.ShardDurationsFile == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(ctx.ShardDurationsFile)
+	if err != nil {
+		log.Printf("[ERROR] Could not read ShardDurationsFile; shards will not be balanced by duration: %v", err)
+		return nil
+	}
+	var values map[string]string
+	if err := json.Unmarshal(data, &values); err != nil {
+		log.Printf("[ERROR] Could not parse ShardDurationsFile; shards will not be balanced by duration: %v", err)
+		return nil
+	}
+	durations := make(map[string]time.Duration)
+	for name, value := range values {
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			log.Printf("[ERROR] Could not parse duration '%s' for probe '%s' in ShardDurationsFile", value, name)
+			continue
+		}
+		durations[name] = d
+	}
+	return durations
+}
+
 // AuditDir creates and returns -audit- directory within WriteDirectory
 func (ctx *VarOptions) AuditDir() string {
 	auditDir := filepath.Join(ctx.GetWriteDirectory(), "audit")
@@ -146,17 +408,41 @@ func (ctx *VarOptions) handleConfigFileExclusions() {
 }
 
 func (ctx *VarOptions) handleProbeExclusions(packName string, probes []Probe) {
+	for _, exclusion := range probeExclusions(packName, probes) {
+		ctx.addExclusion(exclusion.tag)
+	}
+}
+
+// GetExclusionReasons returns the justification given in the config file for each probe or scenario exclusion,
+// keyed by the tag that is excluded (e.g. "probes/kubernetes/<probe>/<scenario>")
+func (ctx *VarOptions) GetExclusionReasons() map[string]string {
+	reasons := make(map[string]string)
+	exclusions := probeExclusions("kubernetes", ctx.ServicePacks.Kubernetes.Probes)                 //TODO: logic specific to service packs should be handled outside of SDK
+	exclusions = append(exclusions, probeExclusions("storage", ctx.ServicePacks.Storage.Probes)...) //TODO: logic specific to service packs should be handled outside of SDK
+	for _, exclusion := range exclusions {
+		reasons[exclusion.tag] = exclusion.reason
+	}
+	return reasons
+}
+
+type exclusion struct {
+	tag    string
+	reason string
+}
+
+func probeExclusions(packName string, probes []Probe) (exclusions []exclusion) {
 	for _, probe := range probes {
 		if probe.IsExcluded() {
-			ctx.addExclusion(fmt.Sprintf("probes/%s/%s", packName, probe.Name))
+			exclusions = append(exclusions, exclusion{fmt.Sprintf("probes/%s/%s", packName, probe.Name), probe.Excluded})
 		} else {
 			for _, scenario := range probe.Scenarios {
 				if scenario.IsExcluded() {
-					ctx.addExclusion(fmt.Sprintf("probes/%s/%s/%s", packName, probe.Name, scenario.Name))
+					exclusions = append(exclusions, exclusion{fmt.Sprintf("probes/%s/%s/%s", packName, probe.Name, scenario.Name), scenario.Excluded})
 				}
 			}
 		}
 	}
+	return
 }
 
 func (ctx *VarOptions) addExclusion(tag string) {
@@ -1,9 +1,15 @@
 package config
 
 import (
+	"bytes"
 	"fmt"
+	"log"
+	"os"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/citihub/probr-sdk/redact"
 )
 
 //
@@ -139,6 +145,15 @@ func TestHandleProbeExclusions(t *testing.T) {
 	checkTagsContainExclusion(config, excludedTag, t)
 }
 
+func TestGetExclusionReasons(t *testing.T) {
+	config, excludedTag := newConfigWithScenarioExclusionAndInclusion()
+	reasons := config.GetExclusionReasons()
+	tag := "probes/kubernetes/container_registry_access/" + excludedTag
+	if len(reasons) != 1 || reasons[tag] != "yes" {
+		t.Errorf("Expected exclusion reason 'yes' for '%s' only, but found %v", tag, reasons)
+	}
+}
+
 func TestAddExclusion(t *testing.T) {
 	config, _ := NewConfig("")
 	value := "exclude-this-tag"
@@ -147,29 +162,6 @@ func TestAddExclusion(t *testing.T) {
 	checkTagsContainExclusion(config, tag, t)
 }
 
-<<<<<<< HEAD
-=======
-func TestSetTags(t *testing.T) {
-	vars, _ := NewConfig("")
-	tagName := "@probes/kubernetes"
-	tagID := "k-cra"
-	expected := 0
-	vars.Tags = tagName
-	tags := map[string][]string{tagName: {tagID}}
-	t.Log(tags)
-	vars.SetTags(tags)
-	for _, tag := range strings.Split(vars.Tags, ",") {
-		t.Log("Tag found:" + tag)
-		if tag == tagName || tag == "@"+tagID {
-			expected = expected + 1
-		}
-	}
-	if expected != 2 {
-		t.Errorf("Tag name and tag ID were not found in VarOptions.Tags")
-	}
-}
-
->>>>>>> upstream/main
 // TestOverwrite ...
 func TestOverwrite(t *testing.T) {
 	vars, _ := NewConfig("")
@@ -184,7 +176,132 @@ func TestOverwrite(t *testing.T) {
 	}
 }
 
+// TestGetMaxConcurrentProbes ...
+func TestGetMaxConcurrentProbes(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected int
+	}{
+		{value: "", expected: 1},
+		{value: "4", expected: 4},
+		{value: "0", expected: 1},
+		{value: "-2", expected: 1},
+		{value: "many", expected: 1},
+	}
+	for _, tt := range tests {
+		vars, _ := NewConfig("")
+		vars.MaxConcurrentProbes = tt.value
+		if got := vars.GetMaxConcurrentProbes(); got != tt.expected {
+			t.Errorf("GetMaxConcurrentProbes() with value '%s' = %d, Expected: %d", tt.value, got, tt.expected)
+		}
+	}
+}
+
+// TestGetProbeTimeout ...
+func TestGetProbeTimeout(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected time.Duration
+	}{
+		{value: "", expected: 0},
+		{value: "90s", expected: 90 * time.Second},
+		{value: "5m", expected: 5 * time.Minute},
+		{value: "-1m", expected: 0},
+		{value: "forever", expected: 0},
+	}
+	for _, tt := range tests {
+		vars, _ := NewConfig("")
+		vars.ProbeTimeout = tt.value
+		vars.RunTimeout = tt.value
+		if got := vars.GetProbeTimeout(); got != tt.expected {
+			t.Errorf("GetProbeTimeout() with value '%s' = %v, Expected: %v", tt.value, got, tt.expected)
+		}
+		if got := vars.GetRunTimeout(); got != tt.expected {
+			t.Errorf("GetRunTimeout() with value '%s' = %v, Expected: %v", tt.value, got, tt.expected)
+		}
+	}
+}
+
+func TestGetRetry(t *testing.T) {
+	vars, _ := NewConfig("")
+	vars.Retry = Retry{MaxAttempts: "2", Backoff: "1s"}
+	vars.ServicePacks.Kubernetes.Probes = []Probe{
+		{
+			Name:  "pod_security",
+			Retry: Retry{MaxAttempts: "3"},
+			Scenarios: []Scenario{
+				{Name: "1.1", Retry: Retry{MaxAttempts: "5", Backoff: "10s"}},
+				{Name: "1.2", Excluded: "not retried"},
+			},
+		},
+	}
+
+	tests := []struct {
+		testName         string
+		probe            string
+		tags             []string
+		expectedAttempts int
+		expectedBackoff  time.Duration
+	}{
+		{"GetRetry_WithScenarioRetry_ShouldUseScenarioRetry", "pod_security", []string{"@probes/kubernetes/pod_security/1.1"}, 5, 10 * time.Second},
+		{"GetRetry_WithoutScenarioRetry_ShouldUseProbeRetry", "pod_security", []string{"@probes/kubernetes/pod_security/1.2"}, 3, 0},
+		{"GetRetry_WithoutProbeRetry_ShouldUseGlobalRetry", "iam", []string{"@probes/kubernetes/iam/1.1"}, 2, time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			retry := vars.GetRetry("kubernetes", tt.probe, tt.tags)
+			if retry.GetMaxAttempts() != tt.expectedAttempts || retry.GetBackoff() != tt.expectedBackoff {
+				t.Errorf("GetRetry() = %d attempts with %v backoff, Expected: %d attempts with %v backoff",
+					retry.GetMaxAttempts(), retry.GetBackoff(), tt.expectedAttempts, tt.expectedBackoff)
+			}
+		})
+	}
+}
+
+func TestGetShard(t *testing.T) {
+	tests := []struct {
+		index, total                 string
+		expectedIndex, expectedTotal int
+	}{
+		{index: "", total: "", expectedIndex: 0, expectedTotal: 1},
+		{index: "2", total: "4", expectedIndex: 2, expectedTotal: 4},
+		{index: "", total: "4", expectedIndex: 0, expectedTotal: 4},
+		{index: "4", total: "4", expectedIndex: 0, expectedTotal: 1},
+		{index: "-1", total: "4", expectedIndex: 0, expectedTotal: 1},
+		{index: "1", total: "many", expectedIndex: 0, expectedTotal: 1},
+	}
+	for _, tt := range tests {
+		vars, _ := NewConfig("")
+		vars.ShardIndex, vars.ShardTotal = tt.index, tt.total
+		if index, total := vars.GetShard(); index != tt.expectedIndex || total != tt.expectedTotal {
+			t.Errorf("GetShard() with index '%s' and total '%s' = %d/%d, Expected: %d/%d", tt.index, tt.total, index, total, tt.expectedIndex, tt.expectedTotal)
+		}
+	}
+}
+
 // Pending... these may be too integration-y for a unit test
+func TestSetRedactionRules(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer func() {
+		log.SetOutput(os.Stderr)
+		defaults, _ := redact.NewRules(nil, nil)
+		redact.SetRules(defaults) // Restore the built-in rules after the test
+	}()
+
+	vars := &VarOptions{Redaction: Redaction{Keys: []string{"pin(", "pin"}, Patterns: []string{"acct-\\d{1,3}", "[unclosed"}}}
+	vars.setRedactionRules()
+
+	for _, expected := range []string{"Redaction key 'pin(' could not be compiled", "Redaction pattern '[unclosed' could not be compiled"} {
+		if !strings.Contains(logs.String(), expected) {
+			t.Errorf("Expected log to contain '%s', but found: %s", expected, logs.String())
+		}
+	}
+	if got := redact.String("acct-123 pin=1234"); got != redact.Mask+" pin="+redact.Mask {
+		t.Errorf("Expected the valid rules to be applied, but found '%s'", got)
+	}
+}
+
 func TestInit(t *testing.T)                       {}
 func TestValidateConfigPath(t *testing.T)         {}
 func TestLogConfigState(t *testing.T)             {}
@@ -16,6 +16,29 @@ func setFromEnvOrDefaults(e *VarOptions) {
 	SetVar(&e.OverwriteHistoricalAudits, "OVERWRITE_AUDITS", "true")
 	SetVar(&e.WriteConfig, "PROBR_LOG_CONFIG", "true")
 	SetVar(&e.ResultsFormat, "PROBR_RESULTS_FORMAT", "cucumber")
+	SetVar(&e.ResultsFormats, "PROBR_RESULTS_FORMATS", []string{})
+	SetVar(&e.ShardIndex, "PROBR_SHARD_INDEX", "0")
+	SetVar(&e.ShardTotal, "PROBR_SHARD_TOTAL", "1")
+	SetVar(&e.ShardDurationsFile, "PROBR_SHARD_DURATIONS_FILE", "")
+	SetVar(&e.BundleKeyFile, "PROBR_BUNDLE_KEY_FILE", "")
+	SetVar(&e.BundlePublicKeyFile, "PROBR_BUNDLE_PUBLIC_KEY_FILE", "")
+	SetSeparatedVar(&e.Redaction.Keys, "PROBR_REDACT_KEYS", "\n", []string{}) // Regular expressions may contain commas
+	SetSeparatedVar(&e.Redaction.Patterns, "PROBR_REDACT_PATTERNS", "\n", []string{})
+	SetVar(&e.ControlsFile, "PROBR_CONTROLS_FILE", "")
+	SetVar(&e.Retention.KeepRuns, "PROBR_RETENTION_KEEP_RUNS", "")
+	SetVar(&e.Retention.MaxAge, "PROBR_RETENTION_MAX_AGE", "")
+	SetVar(&e.Retention.MaxTotalSize, "PROBR_RETENTION_MAX_TOTAL_SIZE", "")
+	SetVar(&e.Retention.PruneOnStart, "PROBR_RETENTION_PRUNE_ON_START", "false")
+	SetVar(&e.RecordHistory, "PROBR_RECORD_HISTORY", "false")
+	SetVar(&e.ExitPolicy.FailOn, "PROBR_EXIT_FAIL_ON", "any")
+	SetVar(&e.ExitPolicy.MinPassRate, "PROBR_EXIT_MIN_PASS_RATE", "")
+	SetVar(&e.ExitPolicy.FailOnSkipped, "PROBR_EXIT_FAIL_ON_SKIPPED", "false")
+	SetVar(&e.ExitPolicy.CriticalTags, "PROBR_EXIT_CRITICAL_TAGS", []string{"@severity/critical"})
+	SetVar(&e.MaxConcurrentProbes, "PROBR_MAX_CONCURRENT_PROBES", "1")
+	SetVar(&e.StaticOverrideDir, "PROBR_STATIC_OVERRIDE_DIR", "")
+	SetVar(&e.Retry.MaxAttempts, "PROBR_RETRY_MAX_ATTEMPTS", "1")
+	SetVar(&e.Retry.Backoff, "PROBR_RETRY_BACKOFF", "5s")
+	SetVar(&e.Retry.RetryableErrors, "PROBR_RETRY_ERRORS", []string{})
 
 	SetVar(&e.ServicePacks.Kubernetes.KeepPods, "PROBR_KEEP_PODS", "false")
 	SetVar(&e.ServicePacks.Kubernetes.KubeConfigPath, "KUBE_CONFIG", getDefaultKubeConfigPath())
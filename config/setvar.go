@@ -18,6 +18,21 @@ func SetVar(field interface{}, varName string, defaultValue interface{}) {
 	}
 }
 
+// SetSeparatedVar behaves as SetVar for a []string field, but an env var is split on separator rather than on commas,
+// so that values which may contain commas, such as regular expressions, can be set. Empty values are ignored.
+func SetSeparatedVar(field *[]string, varName, separator string, defaultValue []string) {
+	if len(*field) == 0 {
+		for _, v := range strings.Split(os.Getenv(varName), separator) {
+			if v != "" {
+				*field = append(*field, v)
+			}
+		}
+	}
+	if len(*field) == 0 {
+		*field = defaultValue
+	}
+}
+
 func setStringVar(value string, varName string, defaultValue string) string {
 	if value == "" { // if field was empty, get value from env var
 		value = os.Getenv(varName)
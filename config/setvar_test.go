@@ -92,3 +92,43 @@ func TestSetStringSliceVar(t *testing.T) {
 		})
 	}
 }
+
+func TestSetSeparatedVar(t *testing.T) {
+	tests := []struct {
+		testName    string
+		value       []string
+		envVarValue string
+		expected    []string
+	}{
+		{
+			testName:    "SetSeparatedVar_WithCommasInValues_ShouldSplitOnSeparatorOnly",
+			envVarValue: "\\d{1,3}\n\nsecret-[a-z]+\n",
+			expected:    []string{"\\d{1,3}", "secret-[a-z]+"},
+		},
+		{
+			testName:    "SetSeparatedVar_WithValueProvided_ShouldIgnoreEnvVar",
+			value:       []string{"provided"},
+			envVarValue: "ignored",
+			expected:    []string{"provided"},
+		},
+		{
+			testName: "SetSeparatedVar_WithoutValue_ShouldReturnDefault",
+			expected: []string{"default"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			originalValue := os.Getenv("ENV_VAR_SEPARATED") // Used to restore env to original state after test
+			defer func() {
+				os.Setenv("ENV_VAR_SEPARATED", originalValue)
+			}()
+			os.Setenv("ENV_VAR_SEPARATED", tt.envVarValue)
+
+			value := tt.value
+			SetSeparatedVar(&value, "ENV_VAR_SEPARATED", "\n", []string{"default"})
+			if !reflect.DeepEqual(value, tt.expected) {
+				t.Errorf("SetSeparatedVar() = %q, Expected: %q", value, tt.expected)
+			}
+		})
+	}
+}
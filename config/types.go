@@ -13,12 +13,54 @@ type VarOptions struct {
 	OverwriteHistoricalAudits string         `yaml:"OverwriteHistoricalAudits"`
 	TagExclusions             []string       `yaml:"TagExclusions"`
 	WriteConfig               string         `yaml:"WriteConfig"`
+	MaxConcurrentProbes       string         `yaml:"MaxConcurrentProbes"`
+	ProbeTimeout              string         `yaml:"ProbeTimeout"`
+	RunTimeout                string         `yaml:"RunTimeout"`
+	StaticOverrideDir         string         `yaml:"StaticOverrideDir"`
+	Retry                     Retry          `yaml:"Retry"`
+	ResultsFormats            []string       `yaml:"ResultsFormats"` // e.g. "pretty:stdout", "junit", "cucumber"
+	ShardIndex                string         `yaml:"ShardIndex"`     // From 0 to ShardTotal-1
+	ShardTotal                string         `yaml:"ShardTotal"`
+	ShardDurationsFile        string         `yaml:"ShardDurationsFile"`  // JSON object of probe names to durations, e.g. {"probe": "90s"}
+	BundleKeyFile             string         `yaml:"BundleKeyFile"`       // PEM encoded ed25519 private key; if set, a signed bundle of the results is written
+	BundlePublicKeyFile       string         `yaml:"BundlePublicKeyFile"` // PEM encoded ed25519 public key, used to verify bundles
+	Redaction                 Redaction      `yaml:"Redaction"`
+	ControlsFile              string         `yaml:"ControlsFile"` // YAML control catalogue mapping framework controls to scenario tags
+	Retention                 Retention      `yaml:"Retention"`
+	RecordHistory             string         `yaml:"RecordHistory"` // If "true", the results of each run are recorded in the results history
+	ExitPolicy                ExitPolicy     `yaml:"ExitPolicy"`
 	Tags                      string         // set by flags
 	VarsFile                  string         // set by flags only
 	NoSummary                 bool           // set by flags only
 	Silent                    bool           // set by flags only
 	Meta                      Meta           // set by CLI options only
 	ResultsFormat             string         // set by flags only
+	DryRun                    string         // set by flags only
+	Resume                    bool           // set by flags only
+}
+
+// Redaction config options, applied in addition to the built-in redaction rules. Fields of config structs that hold
+// secrets should be tagged `secret:"true"`, so that they are masked when the config is logged.
+// When set by env var, each regular expression is on its own line. Expressions that can't be compiled are logged and ignored.
+type Redaction struct {
+	Keys     []string `yaml:"Keys"`     // Regular expressions matching the names of keys whose values are secret
+	Patterns []string `yaml:"Patterns"` // Regular expressions matching secrets wherever they appear
+}
+
+// Retention config options, limiting the run directories kept in the output directory. Empty values mean no limit.
+type Retention struct {
+	KeepRuns     string `yaml:"KeepRuns"`     // Number of most recent runs to keep
+	MaxAge       string `yaml:"MaxAge"`       // Duration, e.g. "720h"
+	MaxTotalSize string `yaml:"MaxTotalSize"` // Bytes, or with a KB, MB or GB suffix, e.g. "500MB"
+	PruneOnStart string `yaml:"PruneOnStart"` // If "true", the policy is applied when Probr starts
+}
+
+// ExitPolicy config options, deciding the exit code of a run from its summary; see audit.ExitPolicy
+type ExitPolicy struct {
+	FailOn        string   `yaml:"FailOn"`        // "any" failed scenario, only "critical" scenarios, or "none"
+	MinPassRate   string   `yaml:"MinPassRate"`   // Percentage of passed and failed scenarios that must pass, e.g. "90"
+	FailOnSkipped string   `yaml:"FailOnSkipped"` // If "true", skipped probes and scenarios whose 'given' was not met fail the run
+	CriticalTags  []string `yaml:"CriticalTags"`  // Tags of the scenarios considered critical when FailOn is "critical"
 }
 
 // Meta config options
@@ -75,6 +117,7 @@ type APIM struct {
 type Probe struct {
 	Name      string     `yaml:"Name"`
 	Excluded  string     `yaml:"Excluded"`
+	Retry     Retry      `yaml:"Retry"`
 	Scenarios []Scenario `yaml:"Scenarios"`
 }
 
@@ -82,6 +125,15 @@ type Probe struct {
 type Scenario struct {
 	Name     string `yaml:"Name"`
 	Excluded string `yaml:"Excluded"`
+	Retry    Retry  `yaml:"Retry"`
+}
+
+// Retry config options, which may be set globally, per probe or per scenario.
+// RetryableErrors are regular expressions matched against the errors of failed steps.
+type Retry struct {
+	MaxAttempts     string   `yaml:"MaxAttempts"`
+	Backoff         string   `yaml:"Backoff"`
+	RetryableErrors []string `yaml:"RetryableErrors"`
 }
 
 // CloudProviders config options
@@ -95,7 +147,7 @@ type Azure struct {
 	TenantID         string `yaml:"TenantID"`
 	SubscriptionID   string `yaml:"SubscriptionID"`
 	ClientID         string `yaml:"ClientID"`
-	ClientSecret     string `yaml:"ClientSecret"`
+	ClientSecret     string `yaml:"ClientSecret" secret:"true"`
 	ResourceGroup    string `yaml:"ResourceGroup"`
 	ResourceLocation string `yaml:"ResourceLocation"`
 	ManagementGroup  string `yaml:"ManagementGroup"`
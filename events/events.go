@@ -0,0 +1,102 @@
+// Package events provides a typed stream of probe lifecycle events, allowing consumers such as progress bars,
+// dashboards and notifiers to follow a probe execution without parsing logs.
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// Type describes the point in the probe lifecycle at which an Event was published
+type Type int
+
+// Type enumeration for the Type type.
+const (
+	ProbeQueued Type = iota
+	ProbeStarted
+	ProbeFinished
+	ScenarioStarted
+	ScenarioFinished
+	StepPassed
+	StepFailed
+)
+
+func (t Type) String() string {
+	return [...]string{"ProbeQueued", "ProbeStarted", "ProbeFinished", "ScenarioStarted", "ScenarioFinished", "StepPassed", "StepFailed"}[t]
+}
+
+// Event is published at each stage of a probe's lifecycle. Fields that do not apply to the Type are left empty.
+type Event struct {
+	Type        Type
+	Time        time.Time
+	Pack        string
+	Probe       string
+	Scenario    string
+	Tags        []string
+	Step        string
+	Description string
+	Status      string      // Probe status or scenario/step result, where known
+	Error       string      // Error text for failed steps and scenarios
+	Payload     interface{} // Audit payload for step events
+}
+
+// Subscriber is called synchronously for every published Event, and should return quickly.
+// Events may be published concurrently when probes are run in parallel.
+type Subscriber func(Event)
+
+// Bus delivers published events to all current subscribers
+type Bus struct {
+	lock        sync.RWMutex
+	subscribers map[int]Subscriber
+	next        int
+}
+
+// DefaultBus is used by the probe engine and audit package to publish lifecycle events
+var DefaultBus = NewBus()
+
+// NewBus creates a Bus without any subscribers
+func NewBus() *Bus {
+	return &Bus{subscribers: make(map[int]Subscriber)}
+}
+
+// Subscribe registers s to receive all events published after this call.
+// The returned function removes the subscription.
+func (b *Bus) Subscribe(s Subscriber) (unsubscribe func()) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	id := b.next
+	b.next++
+	b.subscribers[id] = s
+	return func() {
+		b.lock.Lock()
+		defer b.lock.Unlock()
+		delete(b.subscribers, id)
+	}
+}
+
+// Publish delivers e to all subscribers, setting Time if it has not been provided
+func (b *Bus) Publish(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	b.lock.RLock()
+	subscribers := make([]Subscriber, 0, len(b.subscribers))
+	for _, s := range b.subscribers {
+		subscribers = append(subscribers, s)
+	}
+	b.lock.RUnlock()
+
+	for _, s := range subscribers {
+		s(e)
+	}
+}
+
+// Subscribe registers s to receive all events published to DefaultBus
+func Subscribe(s Subscriber) (unsubscribe func()) {
+	return DefaultBus.Subscribe(s)
+}
+
+// Publish delivers e to all subscribers of DefaultBus
+func Publish(e Event) {
+	DefaultBus.Publish(e)
+}
@@ -0,0 +1,65 @@
+package events
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestBus(t *testing.T) {
+	bus := NewBus()
+
+	var lock sync.Mutex
+	received := make(map[string][]Event)
+	subscriber := func(name string) Subscriber {
+		return func(e Event) {
+			lock.Lock()
+			defer lock.Unlock()
+			received[name] = append(received[name], e)
+		}
+	}
+	unsubscribeFirst := bus.Subscribe(subscriber("first"))
+	bus.Subscribe(subscriber("second"))
+
+	bus.Publish(Event{Type: ProbeQueued, Probe: "probe_one"})
+	unsubscribeFirst()
+	bus.Publish(Event{Type: ProbeStarted, Probe: "probe_one"})
+
+	if len(received["first"]) != 1 {
+		t.Errorf("Expected unsubscribed subscriber to receive 1 event, but found %d", len(received["first"]))
+	}
+	if len(received["second"]) != 2 {
+		t.Fatalf("Expected subscriber to receive 2 events, but found %d", len(received["second"]))
+	}
+	if received["second"][1].Type != ProbeStarted || received["second"][1].Type.String() != "ProbeStarted" {
+		t.Errorf("Expected second event to be ProbeStarted, but found %s", received["second"][1].Type)
+	}
+	if received["second"][0].Time.IsZero() {
+		t.Errorf("Expected Publish to set the event time")
+	}
+}
+
+func TestBus_ConcurrentPublish(t *testing.T) {
+	bus := NewBus()
+	var lock sync.Mutex
+	count := 0
+	bus.Subscribe(func(e Event) {
+		lock.Lock()
+		defer lock.Unlock()
+		count++
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			bus.Publish(Event{Type: StepPassed})
+			unsubscribe := bus.Subscribe(func(e Event) {})
+			unsubscribe()
+		}()
+	}
+	wg.Wait()
+	if count != 10 {
+		t.Errorf("Expected 10 events to be received, but found %d", count)
+	}
+}
@@ -1,12 +1,8 @@
 module github.com/citihub/probr-sdk
 
-go 1.14
+go 1.16
 
 require (
-<<<<<<< HEAD
-=======
-	github.com/Azure-Samples/azure-sdk-for-go-samples v0.0.0-20210318230842-36cb20716fe6
->>>>>>> ca37f2ea8737fdbf02015a4dd8668044b0b6e5d3
 	github.com/Azure/aad-pod-identity v1.7.5
 	github.com/Azure/azure-sdk-for-go v53.3.0+incompatible
 	github.com/Azure/go-autorest/autorest v0.11.18
@@ -14,17 +10,13 @@ require (
 	github.com/Azure/go-autorest/autorest/to v0.4.0
 	github.com/Azure/go-autorest/autorest/validation v0.3.1 // indirect
 	github.com/briandowns/spinner v1.12.0
+	github.com/cucumber/gherkin-go/v11 v11.0.0
 	github.com/cucumber/godog v0.11.0
 	github.com/cucumber/messages-go/v10 v10.0.3
 	github.com/hashicorp/go-hclog v0.14.1
 	github.com/hashicorp/go-plugin v1.4.0
 	github.com/hashicorp/logutils v1.0.0
-	github.com/markbates/pkger v0.17.1
 	github.com/open-policy-agent/opa v0.27.1
-<<<<<<< HEAD
-=======
-	github.com/satori/go.uuid v1.2.0
->>>>>>> ca37f2ea8737fdbf02015a4dd8668044b0b6e5d3
 	gopkg.in/yaml.v2 v2.4.0
 	k8s.io/api v0.19.6
 	k8s.io/apimachinery v0.19.6
@@ -0,0 +1,186 @@
+// Package history keeps a file-based record of the results of each run under the install directory,
+// and answers trend queries across runs such as pass rates, the last green run and flakiness.
+// Each run is stored as a single line of JSON, so that the store needs no external dependencies and
+// a run interrupted while it is recorded cannot corrupt earlier runs.
+package history
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	sdk "github.com/citihub/probr-sdk"
+	"github.com/citihub/probr-sdk/audit"
+)
+
+// FileName is the name of the file that runs are recorded in, within the store's directory
+const FileName = "runs.jsonl"
+
+// Run is the record of a run of a pack
+type Run struct {
+	ID        string // See sdk.NewRunID
+	Pack      string
+	StartTime time.Time
+	EndTime   time.Time
+	OutputDir string
+	Status    string
+	Passed    bool // No probe failed
+	Probes    []ProbeRecord
+	Controls  []ControlRecord `json:",omitempty"`
+}
+
+// ProbeRecord is the result of a probe in a run
+type ProbeRecord struct {
+	Name      string
+	Result    string
+	Scenarios []ScenarioRecord
+}
+
+// ScenarioRecord is the result of a scenario in a run
+type ScenarioRecord struct {
+	ID       string
+	Name     string
+	Result   string
+	Attempts int
+}
+
+// ControlRecord is the result of a control in a run, see audit.Compliance
+type ControlRecord struct {
+	Framework string
+	ID        string
+	Result    string
+}
+
+// Store is a results history in a directory. Its methods are safe for concurrent use.
+type Store struct {
+	dir  string
+	lock sync.RWMutex
+}
+
+// DefaultDir is the directory of the history store within the install directory
+func DefaultDir() string {
+	return filepath.Join(sdk.GlobalConfig.InstallDir, "history")
+}
+
+// Open returns the store in dir, creating the directory if necessary
+func Open(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &Store{dir: dir}, nil
+}
+
+// Record ingests the summary of a run of the named pack into the default store, logging any error
+func Record(pack string, s *audit.SummaryState) {
+	store, err := Open(DefaultDir())
+	if err == nil {
+		_, err = store.Ingest(pack, s)
+	}
+	if err != nil {
+		log.Printf("[ERROR] Run was not recorded in the results history: %v", err)
+	}
+}
+
+// Ingest records the results of a run of the named pack. Probes that were skipped are recorded without scenarios.
+func (st *Store) Ingest(pack string, s *audit.SummaryState) (*Run, error) {
+	snapshot := s.Snapshot()
+	run := &Run{
+		ID:        snapshot.RunID,
+		Pack:      pack,
+		StartTime: snapshot.StartTime,
+		EndTime:   snapshot.EndTime,
+		OutputDir: snapshot.WriteDirectory,
+		Status:    snapshot.Status,
+		Passed:    snapshot.ProbesFailed == 0,
+		Probes:    []ProbeRecord{},
+	}
+	if run.StartTime.IsZero() {
+		run.StartTime = sdk.GlobalConfig.StartTime
+	}
+	if run.ID == "" {
+		run.ID = sdk.NewRunID(run.StartTime)
+	}
+
+	var names []string
+	for name := range snapshot.Probes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		p := snapshot.Probes[name]
+		record := ProbeRecord{Name: name, Result: p.Result, Scenarios: []ScenarioRecord{}}
+		for _, scenario := range p.Scenarios {
+			record.Scenarios = append(record.Scenarios, ScenarioRecord{
+				ID:       scenario.ID,
+				Name:     scenario.Name,
+				Result:   scenario.Result,
+				Attempts: len(scenario.PreviousAttempts) + 1,
+			})
+		}
+		run.Probes = append(run.Probes, record)
+	}
+	if c := snapshot.Compliance(); c != nil {
+		for _, control := range c.Controls {
+			run.Controls = append(run.Controls, ControlRecord{Framework: control.Framework, ID: control.ID, Result: control.Result})
+		}
+	}
+	return run, st.append(run)
+}
+
+func (st *Store) append(run *Run) error {
+	data, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	f, err := os.OpenFile(filepath.Join(st.dir, FileName), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// Runs returns the recorded runs of the named pack, or of every pack if pack is empty, ordered by start time.
+// Lines that cannot be parsed, such as one left by an interrupted write, are skipped.
+func (st *Store) Runs(pack string) ([]*Run, error) {
+	st.lock.RLock()
+	defer st.lock.RUnlock()
+	f, err := os.Open(filepath.Join(st.dir, FileName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var runs []*Run
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		run := &Run{}
+		if err := json.Unmarshal(scanner.Bytes(), run); err != nil {
+			log.Printf("[WARN] Skipping line %d of the results history: %v", line, err)
+			continue
+		}
+		if pack == "" || run.Pack == pack {
+			runs = append(runs, run)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(runs, func(i, j int) bool { return runs[i].StartTime.Before(runs[j].StartTime) })
+	return runs, nil
+}
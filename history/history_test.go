@@ -0,0 +1,134 @@
+package history
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/citihub/probr-sdk/audit"
+	"github.com/citihub/probr-sdk/config"
+	"github.com/cucumber/messages-go/v10"
+)
+
+// newRun returns the summary of a run of a single probe, in which each named scenario passes or fails
+func newRun(results map[string]bool) *audit.SummaryState {
+	summary := audit.NewSummaryState("kubernetes")
+	summary.LogProbeStart("pod_security")
+	probe := summary.GetProbeLog("pod_security")
+	for _, name := range []string{"privileged pods are denied", "host network is denied"} {
+		passed, found := results[name]
+		if !found {
+			continue
+		}
+		scenario := probe.InitializeAuditor(name, []*messages.Pickle_PickleTag{})
+		scenario.AuditScenarioStep("a cluster exists", "", nil, nil)
+		var err error
+		if !passed {
+			err = errors.New("pod was created")
+		}
+		scenario.AuditScenarioStep("the pod is denied", "", nil, err)
+	}
+	summary.ProbeComplete("pod_security")
+	summary.SetProbrStatus()
+	return &summary
+}
+
+func TestStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "probr-history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Vars.WriteDirectory = dir
+	defer func() {
+		os.RemoveAll(dir) // Delete test data after tests
+		config.Vars.WriteDirectory = ""
+	}()
+
+	store, err := Open(filepath.Join(dir, "history"))
+	if err != nil {
+		t.Fatalf("Open() returned unexpected error: %v", err)
+	}
+	if runs, err := store.Runs(""); err != nil || len(runs) != 0 {
+		t.Fatalf("Expected an empty store, but found %v, %v", runs, err)
+	}
+
+	const privileged, hostNetwork = "privileged pods are denied", "host network is denied"
+	runs := []map[string]bool{
+		{privileged: true, hostNetwork: true},
+		{privileged: true, hostNetwork: false},
+		{privileged: true, hostNetwork: true},
+		{privileged: true},
+	}
+	var ids []string
+	for _, results := range runs {
+		run, err := store.Ingest("kubernetes", newRun(results))
+		if err != nil {
+			t.Fatalf("Ingest() returned unexpected error: %v", err)
+		}
+		ids = append(ids, run.ID)
+	}
+	if _, err := store.Ingest("storage", newRun(map[string]bool{privileged: false})); err != nil {
+		t.Fatalf("Ingest() returned unexpected error: %v", err)
+	}
+
+	recorded, err := store.Runs("kubernetes")
+	if err != nil || len(recorded) != len(runs) {
+		t.Fatalf("Expected %d runs of the pack, but found %d, %v", len(runs), len(recorded), err)
+	}
+	if recorded[0].ID != ids[0] || len(recorded[0].Probes) != 1 || len(recorded[0].Probes[0].Scenarios) != 2 {
+		t.Errorf("Expected runs to be recorded in order with their scenarios, but found %+v", recorded[0])
+	}
+
+	t.Run("ProbeTrend", func(t *testing.T) {
+		points, err := store.ProbeTrend("kubernetes", "pod_security")
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []float64{1, 0.5, 1, 1}
+		if len(points) != len(expected) {
+			t.Fatalf("Expected %d points, but found %d", len(expected), len(points))
+		}
+		for i, p := range points {
+			if p.PassRate != expected[i] || p.RunID != ids[i] {
+				t.Errorf("Point %d: PassRate = %v for run %s, Expected: %v for run %s", i, p.PassRate, p.RunID, expected[i], ids[i])
+			}
+		}
+	})
+
+	t.Run("ScenarioTrend", func(t *testing.T) {
+		id := recorded[0].Probes[0].Scenarios[1].ID
+		points, err := store.ScenarioTrend("kubernetes", "pod_security", id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(points) != 3 || points[1].Passed != 0 || points[2].Passed != 1 {
+			t.Errorf("Expected the scenario's result in the 3 runs that included it, but found %+v", points)
+		}
+	})
+
+	t.Run("LastGreen", func(t *testing.T) {
+		run, err := store.LastGreen("kubernetes")
+		if err != nil || run == nil || run.ID != ids[3] {
+			t.Errorf("Expected last green run to be %s, but found %+v, %v", ids[3], run, err)
+		}
+		if run, err := store.LastGreen("storage"); err != nil || run != nil {
+			t.Errorf("Expected no green run, but found %+v, %v", run, err)
+		}
+	})
+
+	t.Run("Flakiness", func(t *testing.T) {
+		scores, err := store.Flakiness("kubernetes", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(scores) != 2 || scores[0].Name != hostNetwork || scores[0].Unstable != 2 || scores[0].Runs != 3 || scores[1].Score != 0 {
+			t.Errorf("Expected host network scenario to be the most flaky, but found %+v", scores)
+		}
+		scores, _ = store.Flakiness("kubernetes", 1)
+		if len(scores) != 1 || scores[0].Runs != 1 {
+			t.Errorf("Expected only the last run to be scored, but found %+v", scores)
+		}
+	})
+}
@@ -0,0 +1,180 @@
+package history
+
+import (
+	"sort"
+	"time"
+
+	"github.com/citihub/probr-sdk/audit"
+)
+
+// TrendPoint is the pass rate of a probe, scenario or control in a run. Only passed and failed results are counted,
+// so scenarios whose 'given' was not met, and controls not covered by the run, are excluded.
+type TrendPoint struct {
+	RunID     string
+	StartTime time.Time
+	Passed    int
+	Total     int
+	PassRate  float64 // From 0 to 1; 0 if Total is 0
+}
+
+// Flakiness describes how often the result of a scenario changed across runs
+type Flakiness struct {
+	Probe      string
+	ScenarioID string
+	Name       string
+	Runs       int     // Runs in which the scenario passed or failed
+	Unstable   int     // Runs in which the result differed from the previous run, or only passed after retrying
+	Score      float64 // Unstable / Runs, from 0 (stable) to 1
+}
+
+// ProbeTrend returns the pass rate of the scenarios of a probe in each run of the pack that included the probe
+func (st *Store) ProbeTrend(pack, probe string) ([]TrendPoint, error) {
+	return st.trend(pack, func(run *Run) (passed, total int, found bool) {
+		for _, p := range run.Probes {
+			if p.Name != probe {
+				continue
+			}
+			for _, s := range p.Scenarios {
+				passed, total = count(s.Result, passed, total)
+			}
+			return passed, total, true
+		}
+		return 0, 0, false
+	})
+}
+
+// ScenarioTrend returns the result of a scenario, identified by its probe and ID, in each run of the pack that included it
+func (st *Store) ScenarioTrend(pack, probe, scenarioID string) ([]TrendPoint, error) {
+	return st.trend(pack, func(run *Run) (passed, total int, found bool) {
+		if s := run.scenario(probe, scenarioID); s != nil {
+			passed, total = count(s.Result, 0, 0)
+			return passed, total, true
+		}
+		return 0, 0, false
+	})
+}
+
+// ControlTrend returns the result of a control in each run of the pack that resolved it against a control catalogue
+func (st *Store) ControlTrend(pack, framework, controlID string) ([]TrendPoint, error) {
+	return st.trend(pack, func(run *Run) (passed, total int, found bool) {
+		for _, c := range run.Controls {
+			if c.Framework == framework && c.ID == controlID {
+				switch c.Result {
+				case audit.ControlPassed:
+					return 1, 1, true
+				case audit.ControlFailed:
+					return 0, 1, true
+				}
+				return 0, 0, true
+			}
+		}
+		return 0, 0, false
+	})
+}
+
+// LastGreen returns the most recent run of the pack in which no probe failed, or nil if there is none
+func (st *Store) LastGreen(pack string) (*Run, error) {
+	runs, err := st.Runs(pack)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(runs) - 1; i >= 0; i-- {
+		if runs[i].Passed {
+			return runs[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// Flakiness scores each scenario over the most recent window runs of the pack, or over all runs if window is 0.
+// Scenarios are ordered from the most flaky.
+func (st *Store) Flakiness(pack string, window int) ([]Flakiness, error) {
+	runs, err := st.Runs(pack)
+	if err != nil {
+		return nil, err
+	}
+	if window > 0 && len(runs) > window {
+		runs = runs[len(runs)-window:]
+	}
+	scores := make(map[string]*Flakiness)
+	previous := make(map[string]bool) // Whether each scenario passed in the last run that included it
+	var keys []string
+	for _, run := range runs {
+		for _, p := range run.Probes {
+			for _, s := range p.Scenarios {
+				passed, total := count(s.Result, 0, 0)
+				if total == 0 {
+					continue
+				}
+				key := p.Name + "/" + s.ID
+				f, found := scores[key]
+				if !found {
+					f = &Flakiness{Probe: p.Name, ScenarioID: s.ID}
+					scores[key] = f
+					keys = append(keys, key)
+				}
+				last, seen := previous[key]
+				if (seen && last != (passed == 1)) || s.Result == "Passed After Retry" {
+					f.Unstable++
+				}
+				f.Name = s.Name
+				f.Runs++
+				previous[key] = passed == 1
+			}
+		}
+	}
+	result := []Flakiness{}
+	for _, key := range keys {
+		f := scores[key]
+		f.Score = float64(f.Unstable) / float64(f.Runs)
+		result = append(result, *f)
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].Score > result[j].Score })
+	return result, nil
+}
+
+// trend returns a point for each run of the pack in which measure finds the probe, scenario or control
+func (st *Store) trend(pack string, measure func(*Run) (passed, total int, found bool)) ([]TrendPoint, error) {
+	runs, err := st.Runs(pack)
+	if err != nil {
+		return nil, err
+	}
+	points := []TrendPoint{}
+	for _, run := range runs {
+		passed, total, found := measure(run)
+		if !found {
+			continue
+		}
+		point := TrendPoint{RunID: run.ID, StartTime: run.StartTime, Passed: passed, Total: total}
+		if total > 0 {
+			point.PassRate = float64(passed) / float64(total)
+		}
+		points = append(points, point)
+	}
+	return points, nil
+}
+
+func (run *Run) scenario(probe, scenarioID string) *ScenarioRecord {
+	for _, p := range run.Probes {
+		if p.Name != probe {
+			continue
+		}
+		for i := range p.Scenarios {
+			if p.Scenarios[i].ID == scenarioID {
+				return &p.Scenarios[i]
+			}
+		}
+	}
+	return nil
+}
+
+// count adds a scenario result to the passed and total counts, if the scenario passed or failed
+func count(result string, passed, total int) (int, int) {
+	switch result {
+	case "Passed", "Passed After Retry":
+		return passed + 1, total + 1
+	case "Failed":
+		return passed, total + 1
+	}
+	return passed, total
+}
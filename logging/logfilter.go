@@ -4,15 +4,16 @@ import (
 	"io"
 	"log"
 
+	"github.com/citihub/probr-sdk/redact"
 	"github.com/hashicorp/logutils"
 )
 
-// SetLogFilter will override the minimum log level.
+// SetLogFilter will override the minimum log level. Secrets are redacted from logs, see redact.NewWriter.
 func SetLogFilter(minLevel string, writer io.Writer) {
 	filter := &logutils.LevelFilter{
 		Levels:   []logutils.LogLevel{"DEBUG", "INFO", "NOTICE", "WARN", "ERROR"},
 		MinLevel: logutils.LogLevel(minLevel),
 		Writer:   writer,
 	}
-	log.SetOutput(filter)
+	log.SetOutput(redact.NewWriter(filter))
 }
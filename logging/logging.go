@@ -5,6 +5,7 @@ import (
 	"log"
 	"os"
 
+	"github.com/citihub/probr-sdk/redact"
 	hclog "github.com/hashicorp/go-hclog"
 )
 
@@ -18,7 +19,7 @@ var (
 
 func init() {
 	logger = newHCLogger("")
-	logWriter = logger.StandardWriter(&hclog.StandardLoggerOptions{InferLevels: true})
+	logWriter = redact.NewWriter(logger.StandardWriter(&hclog.StandardLoggerOptions{InferLevels: true}))
 
 	// set up the default std library logger to use our output
 	log.SetFlags(0)
@@ -3,6 +3,7 @@ package plugin
 import (
 	"log"
 
+	"github.com/citihub/probr-sdk/redact"
 	hclog "github.com/hashicorp/go-hclog"
 	hcplugin "github.com/hashicorp/go-plugin"
 )
@@ -50,7 +51,7 @@ func Serve(opts *ServeOpts) {
 			Level:      hclog.Trace,
 			JSONFormat: true,
 		})
-		log.SetOutput(logger.StandardWriter(&hclog.StandardLoggerOptions{InferLevels: true}))
+		log.SetOutput(redact.NewWriter(logger.StandardWriter(&hclog.StandardLoggerOptions{InferLevels: true})))
 	}
 
 	// Plugin implementation
@@ -0,0 +1,130 @@
+package probeengine
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/citihub/probr-sdk/config"
+	"github.com/citihub/probr-sdk/utils"
+)
+
+// Checkpoint records the progress of a ProbeStore, so that an interrupted run can be resumed
+type Checkpoint struct {
+	Name   string
+	Tags   string
+	Probes map[string]*ProbeCheckpoint
+}
+
+// ProbeCheckpoint records the status of a probe, and where its audit is written once completed
+type ProbeCheckpoint struct {
+	Status    string
+	AuditPath string
+}
+
+// resumable statuses are those of probes that completed and do not need to be run again
+var resumable = []ProbeStatus{CompleteSuccess, CompleteFail}
+
+// checkpointPath returns a path that is the same for every run of this ProbeStore, unlike sdk.GlobalConfig.OutputDir
+func (ps *ProbeStore) checkpointPath() string {
+	return filepath.Join(config.Vars.GetWriteDirectory(), "checkpoints", ps.Name+".json")
+}
+
+// writeCheckpoint persists the status of every probe in the store. ps.Lock must be held by the caller.
+func (ps *ProbeStore) writeCheckpoint() {
+	checkpoint := Checkpoint{
+		Name:   ps.Name,
+		Tags:   ps.Tags,
+		Probes: make(map[string]*ProbeCheckpoint),
+	}
+	for name, probe := range ps.Probes {
+		checkpoint.Probes[name] = &ProbeCheckpoint{
+			Status:    probe.Status.String(),
+			AuditPath: ps.Summary.GetProbeLog(name).Path,
+		}
+	}
+
+	path := ps.checkpointPath()
+	_ = os.MkdirAll(filepath.Dir(path), 0755) // Creates if not already existing
+	if err := ioutil.WriteFile(path, utils.JSON(checkpoint), 0644); err != nil {
+		log.Printf("[ERROR] Failed to write checkpoint '%s': %v", path, err)
+	}
+}
+
+// finishCheckpoint removes the checkpoint once every probe has run to completion, so that a later run with Resume set
+// runs them all again rather than resuming a finished run. The checkpoint is kept if any probe was interrupted or could
+// not be completed, so that those probes are run when the run is resumed. ps.Lock must be held by the caller.
+func (ps *ProbeStore) finishCheckpoint() {
+	for _, probe := range ps.Probes {
+		switch *probe.Status {
+		case Pending, Running, Error:
+			return
+		}
+	}
+	path := ps.checkpointPath()
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		log.Printf("[ERROR] Failed to remove checkpoint '%s': %v", path, err)
+	}
+}
+
+// readCheckpoint reads the checkpoint written by an earlier run of this ProbeStore
+func (ps *ProbeStore) readCheckpoint() (checkpoint Checkpoint, err error) {
+	data, err := ioutil.ReadFile(ps.checkpointPath())
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(data, &checkpoint)
+	return
+}
+
+// resumeFromCheckpoint restores the status and audit of each probe completed by an earlier run,
+// returning the restored statuses keyed by probe name. Probes that did not complete will be run again.
+// The checkpoint is ignored if it was written with different tags, as it may not include the probes to be run.
+func (ps *ProbeStore) resumeFromCheckpoint() map[string]ProbeStatus {
+	checkpoint, err := ps.readCheckpoint()
+	if os.IsNotExist(err) {
+		log.Printf("[NOTICE] No checkpoint found for '%s'; all probes will be run", ps.Name)
+		return nil
+	} else if err != nil {
+		log.Printf("[ERROR] Failed to read checkpoint for '%s'; all probes will be run: %v", ps.Name, err)
+		return nil
+	}
+	if checkpoint.Tags != ps.Tags {
+		log.Printf("[WARN] Checkpoint for '%s' was written with tags '%s' rather than '%s'; all probes will be run", ps.Name, checkpoint.Tags, ps.Tags)
+		return nil
+	}
+
+	ps.Lock.Lock()
+	defer ps.Lock.Unlock()
+	resumed := make(map[string]ProbeStatus)
+	for name, probe := range ps.Probes {
+		c, found := checkpoint.Probes[name]
+		if !found {
+			continue
+		}
+		status, completed := parseProbeStatus(c.Status)
+		if !completed {
+			continue
+		}
+		if err := ps.Summary.RestoreProbe(name, c.AuditPath); err != nil {
+			log.Printf("[ERROR] Failed to restore audit for probe '%s'; it will be run again: %v", name, err)
+			continue
+		}
+		*probe.Status = status
+		resumed[name] = status
+		log.Printf("[INFO] Probe '%s' was completed by an earlier run with status %s", name, status)
+	}
+	return resumed
+}
+
+// parseProbeStatus returns the status with the given name, and whether it is resumable
+func parseProbeStatus(name string) (ProbeStatus, bool) {
+	for _, status := range resumable {
+		if status.String() == name {
+			return status, true
+		}
+	}
+	return Pending, false
+}
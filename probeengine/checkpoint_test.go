@@ -0,0 +1,98 @@
+package probeengine
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/citihub/probr-sdk/audit"
+	"github.com/citihub/probr-sdk/config"
+	"github.com/citihub/probr-sdk/utils"
+	"github.com/cucumber/messages-go/v10"
+)
+
+func TestExecAllProbes_Resume(t *testing.T) {
+	config.Vars.WriteDirectory = filepath.Join(testFolder, utils.RandomString(10))
+	defer func() {
+		os.RemoveAll(config.Vars.WriteDirectory) // Delete test data after tests
+		config.Vars.WriteDirectory = ""
+		probeHandlerFunc = GodogProbeHandler // Restoring to original function after test
+	}()
+
+	names := []string{"passing_probe", "failing_probe", "interrupted_probe"}
+	run := func(resume bool, interrupt bool) (*ProbeStore, *audit.SummaryState, []string, int) {
+		summary := audit.NewSummaryState(probeStoreName)
+		ps := NewProbeStore(probeStoreName, "", &summary)
+		ps.Resume = resume
+		for _, name := range names {
+			ps.AddProbe(TestProbe{name: name})
+		}
+
+		var executed []string
+		probeHandlerFunc = func(ctx context.Context, probe *GodogProbe) (int, *bytes.Buffer, error) {
+			executed = append(executed, probe.Name)
+			if probe.Name == "interrupted_probe" && interrupt {
+				return 2, nil, context.Canceled
+			}
+			var err error
+			if probe.Name == "failing_probe" {
+				err = errors.New("failed")
+			}
+			scenario := ps.Summary.GetProbeLog(probe.Name).InitializeAuditor("scenario", []*messages.Pickle_PickleTag{})
+			scenario.AuditScenarioStep("a given", "", nil, nil)
+			scenario.AuditScenarioStep("a step", "", nil, err)
+			if err != nil {
+				return 1, nil, nil
+			}
+			return 0, nil, nil
+		}
+		status, _ := ps.ExecAllProbes(context.Background())
+		return ps, &summary, executed, status
+	}
+
+	ps, _, executed, _ := run(false, true)
+	if len(executed) != 3 {
+		t.Fatalf("Expected all probes to be run before resuming, but found %v", executed)
+	}
+	checkpoint, err := ps.readCheckpoint()
+	if err != nil {
+		t.Fatalf("Expected the checkpoint of an interrupted run to be kept: %v", err)
+	}
+	interruptedStatus := map[string]ProbeStatus{"passing_probe": CompleteSuccess, "failing_probe": CompleteFail, "interrupted_probe": Error}
+	for name, expected := range interruptedStatus {
+		if checkpoint.Probes[name] == nil || checkpoint.Probes[name].Status != expected.String() {
+			t.Errorf("Expected checkpoint for probe '%s' to have status %s, but found %+v", name, expected, checkpoint.Probes[name])
+		}
+	}
+
+	ps, summary, executed, status := run(true, false)
+	if len(executed) != 1 || executed[0] != "interrupted_probe" {
+		t.Errorf("Expected only the interrupted probe to be run when resuming, but found %v", executed)
+	}
+	if status != 1 {
+		t.Errorf("ExecAllProbes() = %v, Expected: %v", status, 1)
+	}
+	expectedStatus := map[string]ProbeStatus{"passing_probe": CompleteSuccess, "failing_probe": CompleteFail, "interrupted_probe": CompleteSuccess}
+	for name, expected := range expectedStatus {
+		if *ps.Probes[name].Status != expected {
+			t.Errorf("Expected probe '%s' to have status %s, but found %s", name, expected, ps.Probes[name].Status)
+		}
+	}
+	if summary.ProbesPassed != 2 || summary.ProbesFailed != 1 {
+		t.Errorf("Expected prior results to be merged into the summary (2 passed, 1 failed), but found %d passed, %d failed", summary.ProbesPassed, summary.ProbesFailed)
+	}
+	if len(summary.Probes["failing_probe"].Scenarios) != 1 || summary.Probes["failing_probe"].Result != "Failed" {
+		t.Errorf("Expected audit of failing_probe to be restored, but found %+v", summary.Probes["failing_probe"])
+	}
+
+	if _, err := ps.readCheckpoint(); !os.IsNotExist(err) {
+		t.Errorf("Expected the checkpoint to be removed once every probe has completed, but reading it returned %v", err)
+	}
+	_, _, executed, _ = run(true, false)
+	if len(executed) != 3 {
+		t.Errorf("Expected all probes to be run when resuming a completed run, but found %v", executed)
+	}
+}
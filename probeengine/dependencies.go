@@ -0,0 +1,90 @@
+package probeengine
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+)
+
+// probeDependencies returns the names of the probes that the supplied probe depends on, if any
+func probeDependencies(probe Probe) []string {
+	if dp, ok := probe.(DependentProbe); ok {
+		return dp.Dependencies()
+	}
+	return nil
+}
+
+// executionOrder sorts the probe names topologically, so that every probe follows its dependencies.
+// Probes without a dependency relationship are ordered by name. Unknown dependencies and cycles return an error.
+func (ps *ProbeStore) executionOrder() ([]string, error) {
+	ps.Lock.RLock()
+	defer ps.Lock.RUnlock()
+
+	remaining := make(map[string]int)       // Number of unsorted dependencies per probe
+	dependents := make(map[string][]string) // Probes that depend on each probe
+	for name, probe := range ps.Probes {
+		remaining[name] = len(probe.Dependencies)
+		for _, dependency := range probe.Dependencies {
+			if _, exists := ps.Probes[dependency]; !exists {
+				return nil, fmt.Errorf("probe '%s' depends on unknown probe '%s'", name, dependency)
+			}
+			dependents[dependency] = append(dependents[dependency], name)
+		}
+	}
+
+	var ready, order []string
+	for name, count := range remaining {
+		if count == 0 {
+			ready = append(ready, name)
+		}
+	}
+	for len(ready) > 0 {
+		sort.Strings(ready)
+		name := ready[0]
+		ready = ready[1:]
+		order = append(order, name)
+		for _, dependent := range dependents[name] {
+			remaining[dependent]--
+			if remaining[dependent] == 0 {
+				ready = append(ready, dependent)
+			}
+		}
+	}
+
+	if len(order) < len(ps.Probes) {
+		var cyclic []string
+		for name, count := range remaining {
+			if count > 0 {
+				cyclic = append(cyclic, name)
+			}
+		}
+		sort.Strings(cyclic)
+		return nil, fmt.Errorf("dependency cycle detected between probes %v", cyclic)
+	}
+	return order, nil
+}
+
+// execProbeAfterDependencies waits for the dependencies of the named probe to complete, then executes it
+// if they all succeeded. Otherwise the probe is marked as DependencyFailed without being run.
+func (ps *ProbeStore) execProbeAfterDependencies(ctx context.Context, name string, done map[string]chan struct{}) (int, error) {
+	p, err := ps.GetProbe(name)
+	if err != nil {
+		return 1, err // Failure
+	}
+	for _, dependency := range p.Dependencies {
+		<-done[dependency]
+		ps.Lock.Lock()
+		succeeded := *ps.Probes[dependency].Status == CompleteSuccess
+		if !succeeded {
+			*p.Status = DependencyFailed
+			ps.Summary.LogProbeDependencyFailed(name, dependency)
+		}
+		ps.Lock.Unlock()
+		if !succeeded {
+			log.Printf("[NOTICE] Probe '%s' was not run because its dependency '%s' did not succeed", name, dependency)
+			return 0, nil // The failed dependency is responsible for the overall status
+		}
+	}
+	return ps.ExecProbe(ctx, name)
+}
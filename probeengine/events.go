@@ -0,0 +1,47 @@
+package probeengine
+
+import (
+	"sync"
+
+	"github.com/cucumber/godog"
+
+	"github.com/citihub/probr-sdk/events"
+)
+
+// featureProbes maps the feature file path provided to godog to the probe being run,
+// so that scenario hooks are able to identify which probe a scenario belongs to
+var featureProbes sync.Map
+
+// publishProbeEvent publishes a lifecycle event for the named probe, including its current status
+func (ps *ProbeStore) publishProbeEvent(eventType events.Type, name string) {
+	ps.Lock.RLock()
+	probe, exists := ps.Probes[name]
+	if !exists {
+		ps.Lock.RUnlock()
+		return
+	}
+	e := events.Event{
+		Type:   eventType,
+		Pack:   probe.Pack,
+		Probe:  probe.Name,
+		Status: probe.Status.String(),
+	}
+	ps.Lock.RUnlock()
+	events.Publish(e)
+}
+
+// publishScenarioEvent publishes a lifecycle event for the supplied scenario
+func publishScenarioEvent(eventType events.Type, s *godog.Scenario) {
+	e := events.Event{
+		Type:     eventType,
+		Scenario: s.Name,
+	}
+	for _, t := range s.Tags {
+		e.Tags = append(e.Tags, t.GetName())
+	}
+	if probe, found := featureProbes.Load(s.Uri); found {
+		e.Pack = probe.(*GodogProbe).Pack
+		e.Probe = probe.(*GodogProbe).Name
+	}
+	events.Publish(e)
+}
@@ -2,69 +2,301 @@ package probeengine
 
 import (
 	"bytes"
+	"context"
+	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
 	"os"
+	"strings"
+	"sync"
 
 	"github.com/cucumber/godog"
-	"github.com/cucumber/godog/colors"
 
 	sdk "github.com/citihub/probr-sdk"
+	"github.com/citihub/probr-sdk/config"
 )
 
-// GodogProbeHandler is a wrapper to allow for multiple probe handlers in the future
-func GodogProbeHandler(probe *GodogProbe) (int, *bytes.Buffer, error) {
-	return toFileGodogProbeHandler(probe)
+// Output types supported by GodogProbeHandler, selected via config.Vars.OutputType
+const (
+	OutputTypeIO    = "IO"    // Results are written to files in the cucumber directory
+	OutputTypeInMem = "INMEM" // Results are held in memory only
+	OutputTypeBoth  = "BOTH"  // Results are held in memory and written to files in the cucumber directory
+)
+
+// GodogProbeHandler runs the probe using the handler for the probe's OutputType.
+// The returned buffer is nil unless the output type holds results in memory.
+func GodogProbeHandler(ctx context.Context, probe *GodogProbe) (int, *bytes.Buffer, error) {
+	switch strings.ToUpper(probe.OutputType) {
+	case OutputTypeInMem:
+		return inMemGodogProbeHandler(ctx, probe)
+	case OutputTypeBoth:
+		return toFileGodogProbeHandler(ctx, probe, new(bytes.Buffer))
+	case OutputTypeIO, "":
+	default:
+		log.Printf("[WARN] Unknown output type '%s'; results will be written to file", probe.OutputType)
+	}
+	return toFileGodogProbeHandler(ctx, probe, nil)
+}
+
+// toFileGodogProbeHandler writes results to file in each results format, and also to mem if it is provided
+func toFileGodogProbeHandler(ctx context.Context, gd *GodogProbe, mem *bytes.Buffer) (int, *bytes.Buffer, error) {
+	name := gd.Name
+	if gd.Attempt > 1 {
+		name = fmt.Sprintf("%s_attempt%d", gd.Name, gd.Attempt) // Keep the results of earlier attempts
+	}
+
+	var outputs resultsOutputs
+	var files []*os.File
+	for _, rf := range getResultsFormats() {
+		var w io.Writer = os.Stdout
+		if !rf.stdout {
+			o, err := getResultsOutputPath(name, rf.format)
+			if err != nil {
+				closeResultsFiles(files)
+				return -1, nil, err
+			}
+			files = append(files, o)
+			w = o
+		}
+		if mem != nil && len(outputs) == 0 {
+			w = io.MultiWriter(w, mem) // Results are held in memory in the first format only
+		}
+		outputs = append(outputs, resultsOutput{format: rf.format, w: w})
+	}
+	status, runErr := runTestSuite(ctx, outputs, gd)
+	closeResultsFiles(files)
+
+	if runErr != nil {
+		return status, nil, runErr // The results of an abandoned suite are incomplete
+	}
+	return status, mem, nil
+}
+
+// closeResultsFiles closes the files results were written to, removing any that are empty
+func closeResultsFiles(files []*os.File) {
+	for _, o := range files {
+		//FUDGE! If the tests are skipped due to tags, then an empty file may
+		//be left lingering.  This will have a non-zero size as we've actually
+		//had to create the file prior to the test run.  If it's
+		//less than 4 bytes, it's fairly certain that this will indeed be empty
+		//and can be removed.
+		i, err := o.Stat()
+		o.Close()
+		if err == nil && i.Size() < 4 {
+			err = os.Remove(o.Name())
+			if err != nil {
+				log.Printf("[WARN] unable to remove empty test result file: %v", err)
+			}
+		}
+	}
 }
 
-func toFileGodogProbeHandler(gd *GodogProbe) (int, *bytes.Buffer, error) {
-	o, err := getOutputPath(gd.Name)
+// inMemGodogProbeHandler is how we use probes within an application instead of CLI runtime.
+// Results are held in memory in the first results format; formats written to stdout are still written.
+func inMemGodogProbeHandler(ctx context.Context, gd *GodogProbe) (int, *bytes.Buffer, error) {
+	var t []byte
+	o := bytes.NewBuffer(t)
+	var outputs resultsOutputs
+	for i, rf := range getResultsFormats() {
+		if i == 0 {
+			outputs = append(outputs, resultsOutput{format: rf.format, w: o})
+		} else if rf.stdout {
+			outputs = append(outputs, resultsOutput{format: rf.format, w: os.Stdout})
+		}
+	}
+	status, err := runTestSuite(ctx, outputs, gd)
 	if err != nil {
-		return -1, nil, err
+		return status, nil, err // The results of an abandoned suite are incomplete
 	}
+	return status, o, nil
+}
+
+// resultsFormat is a godog results format, and whether it is written to stdout rather than to file
+type resultsFormat struct {
+	format string
+	stdout bool
+}
 
-	status, err := runTestSuite(o, gd)
+// getResultsFormats parses config.Vars.ResultsFormats, where each entry is a godog format optionally
+// followed by ":stdout". If none are configured, sdk.GlobalConfig.GodogResultsFormat is written to file.
+func getResultsFormats() (formats []resultsFormat) {
+	for _, entry := range config.Vars.ResultsFormats {
+		parts := strings.SplitN(entry, ":", 2)
+		rf := resultsFormat{format: strings.TrimSpace(parts[0])}
+		if len(parts) > 1 {
+			destination := strings.TrimSpace(parts[1])
+			if strings.ToLower(destination) == "stdout" {
+				rf.stdout = true
+			} else if destination != "" {
+				log.Printf("[WARN] Unknown destination '%s' for results format '%s'; results will be written to file", destination, rf.format)
+			}
+		}
+		if rf.format != "" {
+			formats = append(formats, rf)
+		}
+	}
+	if len(formats) == 0 {
+		formats = append(formats, resultsFormat{format: sdk.GlobalConfig.GodogResultsFormat})
+	}
+	return
+}
+
+// resultsOutput is a results format, and the writer that results in that format are written to
+type resultsOutput struct {
+	format string
+	w      io.Writer
+}
+
+// resultsOutputs are the results formats a probe is run with, each written to its own destination
+type resultsOutputs []resultsOutput
+
+// This is a var-func in order to be able to mock the godog test run during testing.
+var runGodogSuite = func(suite godog.TestSuite) int {
+	return suite.Run()
+}
 
-	//FUDGE! If the tests are skipped due to tags, then an empty file may
-	//be left lingering.  This will have a non-zero size as we've actually
-	//had to create the file prior to the test run (see line 31).  If it's
-	//less than 4 bytes, it's fairly certain that this will indeed be empty
-	//and can be removed.
-	i, err := o.Stat()
-	s := i.Size()
-	o.Close()
-	if s < 4 {
-		err = os.Remove(o.Name())
+// runTestSuite executes the godog test suite for the probe, returning early if ctx is done.
+// godog writes results in a single format per run, so the suite is run once for each output: the first run is audited
+// and determines the returned status, and later runs are only used to write results in their format (see audit.Probe.Pause).
+// godog cannot be interrupted, so a suite that outlives ctx is abandoned (see suiteRun.abandon);
+// once this returns, nothing more is written to the outputs or recorded in the probe's audit.
+func runTestSuite(ctx context.Context, o resultsOutputs, gd *GodogProbe) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 2, fmt.Errorf("probe '%s' was not started: %w", gd.Name, err)
+	}
+	featurePath, err := resolveFeaturePath(gd.FeaturePath)
+	if err != nil {
+		return 2, fmt.Errorf("probe '%s' was not started: %v", gd.Name, err)
+	}
+	if len(o) == 0 {
+		o = resultsOutputs{{format: sdk.GlobalConfig.GodogResultsFormat, w: ioutil.Discard}} // The probe is still run and audited
+	}
+
+	var status int
+	for i, output := range o {
+		s, err := runTestSuiteOutput(ctx, output, gd, featurePath, i == 0)
 		if err != nil {
-			log.Printf("[WARN] unable to remove empty test result file: %v", err)
+			return s, err
+		}
+		if i == 0 {
+			status = s
 		}
 	}
-	return status, nil, err
+	return status, nil
 }
 
-// Not currently in use, but leave this here for future reference
-// This is how we might use probes within an application instead of CLI runtime
-// func inMemGodogProbeHandler(gd *GodogProbe) (int, *bytes.Buffer, error) {
-// 	var t []byte
-// 	o := bytes.NewBuffer(t)
-// 	status, err := runTestSuite(o, gd)
-// 	return status, o, err
-// }
+// runTestSuiteOutput runs the godog test suite once, writing results in the format of the output.
+// Unless audited is set, the probe's audit is paused until the run ends.
+func runTestSuiteOutput(ctx context.Context, output resultsOutput, gd *GodogProbe, featurePath string, audited bool) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 2, fmt.Errorf("probe '%s' did not complete: %w", gd.Name, err)
+	}
+	format := output.format
+	if godog.FindFmt(format) == nil {
+		log.Printf("[WARN] Unknown results format '%s'; results will be written as cucumber", format)
+		format = "cucumber"
+	}
+	run := newSuiteRun(gd, featurePath, output.w)
+	featureProbes.Store(featurePath, gd)
 
-func runTestSuite(o io.Writer, gd *GodogProbe) (int, error) {
-	opts := godog.Options{
-		Format: sdk.GlobalConfig.GodogResultsFormat,
-		Output: colors.Colored(o),
-		Paths:  []string{gd.FeaturePath},
-		Tags:   gd.Tags,
+	scenarioInitializer := gd.ScenarioInitializer
+	resume := func() {}
+	if audited {
+		scenarioInitializer = func(sc *godog.ScenarioContext) {
+			if gd.ScenarioInitializer != nil {
+				gd.ScenarioInitializer(sc)
+			}
+			run.auditScenario(sc) // After the probe's hooks; see auditScenario
+		}
+	} else if gd.summary != nil {
+		resume = gd.summary.GetProbeLog(gd.Name).Pause()
 	}
 
-	status := godog.TestSuite{
-		Name:                 gd.Name,
-		TestSuiteInitializer: gd.ProbeInitializer,
-		ScenarioInitializer:  gd.ScenarioInitializer,
-		Options:              &opts,
-	}.Run()
+	result := make(chan int, 1)
+	go func() {
+		defer resume() // Not until godog returns, so that an abandoned run can't audit the probe once it is resumed
+		result <- runGodogSuite(godog.TestSuite{
+			Name:                 gd.Name,
+			TestSuiteInitializer: gd.ProbeInitializer,
+			ScenarioInitializer:  scenarioInitializer,
+			Options: &godog.Options{
+				Format: format,
+				Output: run, // Discards anything written once the run is abandoned
+				Tags:   gd.Tags,
+				Paths:  []string{featurePath},
+			},
+		})
+	}()
 
-	return status, nil
+	select {
+	case status := <-result:
+		run.detach()
+		return status, nil
+	case <-ctx.Done():
+		run.abandon()
+		return 2, fmt.Errorf("probe '%s' did not complete: %w", gd.Name, ctx.Err())
+	}
+}
+
+// suiteRun is a run of the godog test suite of a probe. It is passed to godog as the output of the run, and audits
+// the run's scenarios (see auditScenario), so that everything the run writes or audits can be stopped once the run
+// is abandoned.
+type suiteRun struct {
+	probe       *GodogProbe
+	featurePath string
+	w           runWriter  // Discards its output once the run is abandoned
+	lock        sync.Mutex // Held while the run writes or audits, so that abandon waits for it to finish
+	abandoned   bool
+}
+
+func newSuiteRun(probe *GodogProbe, featurePath string, w io.Writer) *suiteRun {
+	run := &suiteRun{probe: probe, featurePath: featurePath}
+	run.w = runWriter{run: run, w: w}
+	return run
+}
+
+// Write writes to the run's output until the run is abandoned
+func (run *suiteRun) Write(p []byte) (int, error) {
+	return run.w.Write(p)
+}
+
+// do calls fn unless the run has been abandoned. The run can't be abandoned until fn returns.
+func (run *suiteRun) do(fn func()) {
+	run.lock.Lock()
+	defer run.lock.Unlock()
+	if !run.abandoned {
+		fn()
+	}
+}
+
+// abandon stops a run that is still executing from affecting the results of its probe: it is detached from the
+// probe, and anything it writes or audits from now on is discarded. Its results files may then be closed.
+func (run *suiteRun) abandon() {
+	run.detach()
+	run.lock.Lock()
+	run.abandoned = true
+	run.lock.Unlock()
+	log.Printf("[WARN] Abandoned the test suite of probe '%s'; any steps still running will not be audited", run.probe.Name)
+}
+
+// detach removes the feature file's entry in featureProbes, unless another run of the probe has replaced it
+func (run *suiteRun) detach() {
+	if probe, found := featureProbes.Load(run.featurePath); found && probe == run.probe {
+		featureProbes.Delete(run.featurePath)
+	}
+}
+
+// runWriter writes to w until its run is abandoned
+type runWriter struct {
+	run *suiteRun
+	w   io.Writer
+}
+
+func (rw runWriter) Write(p []byte) (n int, err error) {
+	n = len(p) // Reported as written once discarded
+	rw.run.do(func() {
+		n, err = rw.w.Write(p)
+	})
+	return
 }
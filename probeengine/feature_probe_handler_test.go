@@ -0,0 +1,148 @@
+package probeengine
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	sdk "github.com/citihub/probr-sdk"
+	"github.com/citihub/probr-sdk/audit"
+	"github.com/citihub/probr-sdk/config"
+	"github.com/citihub/probr-sdk/utils"
+	"github.com/cucumber/godog"
+	"github.com/cucumber/messages-go/v10"
+)
+
+func TestGetResultsFormats(t *testing.T) {
+	defer func() {
+		config.Vars.ResultsFormats = nil
+	}()
+
+	tests := []struct {
+		testName       string
+		resultsFormats []string
+		expected       []resultsFormat
+	}{
+		{
+			testName: "GetResultsFormats_WithNoFormats_ShouldUseGodogResultsFormat",
+			expected: []resultsFormat{{format: sdk.GlobalConfig.GodogResultsFormat}},
+		},
+		{
+			testName:       "GetResultsFormats_WithMultipleFormats_ShouldReturnEachFormat",
+			resultsFormats: []string{"pretty:stdout", "junit", "cucumber:"},
+			expected:       []resultsFormat{{format: "pretty", stdout: true}, {format: "junit"}, {format: "cucumber"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			config.Vars.ResultsFormats = tt.resultsFormats
+			if got := getResultsFormats(); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("getResultsFormats() = %v, Expected: %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestGetResultsOutputPath(t *testing.T) {
+	d := filepath.Join(testFolder, utils.RandomString(10))
+	originalCucumberDirFunc := cucumberDirFunc
+	defer func() {
+		os.RemoveAll(d)                           // Delete test data after tests
+		cucumberDirFunc = originalCucumberDirFunc // Restoring to original function after test
+	}()
+	cucumberDirFunc = func() string {
+		_ = os.MkdirAll(d, 0755) // Creates if not already existing
+		return d
+	}
+
+	tests := []struct {
+		format   string
+		expected string
+	}{
+		{format: "cucumber", expected: "test_file.json"},
+		{format: "junit", expected: "test_file.junit.xml"},
+		{format: "pretty", expected: "test_file.pretty.txt"},
+	}
+	for _, tt := range tests {
+		file, err := getResultsOutputPath("test_file", tt.format)
+		if err != nil {
+			t.Fatalf("getResultsOutputPath() returned unexpected error: %v", err)
+		}
+		file.Close()
+		if file.Name() != filepath.Join(d, tt.expected) {
+			t.Errorf("getResultsOutputPath() for format '%s' = %s, Expected: %s", tt.format, file.Name(), filepath.Join(d, tt.expected))
+		}
+	}
+}
+
+func TestRunTestSuite_WithTimeout_ShouldDiscardLateResults(t *testing.T) {
+	config.Vars.WriteDirectory = filepath.Join(testFolder, utils.RandomString(10))
+	originalRunGodogSuite := runGodogSuite
+	defer func() {
+		os.RemoveAll(config.Vars.WriteDirectory) // Delete test data after tests
+		config.Vars.WriteDirectory = ""
+		runGodogSuite = originalRunGodogSuite // Restoring to original function after test
+	}()
+
+	summary := audit.NewSummaryState(probeStoreName)
+	ps := NewProbeStore(probeStoreName, "", &summary)
+	ps.AddProbe(TestProbe{name: probeName})
+	gd := ps.Probes[probeName]
+	gd.FeaturePath, _ = filepath.Abs(filepath.Join(config.Vars.WriteDirectory, "slow.feature"))
+
+	timedOut, stepEnded := make(chan struct{}), make(chan struct{})
+	completed, finished := make(chan struct{}), make(chan struct{})
+	runGodogSuite = func(suite godog.TestSuite) int {
+		defer close(finished)
+		// Audits the scenario as the hooks registered by auditScenario would
+		a := &scenarioAuditor{run: suite.Options.Output.(*suiteRun)}
+		pickle := &messages.Pickle{
+			Id:    "1",
+			Uri:   suite.Options.Paths[0],
+			Name:  "a slow scenario",
+			Steps: []*messages.Pickle_PickleStep{{Text: "a slow step"}},
+		}
+		a.beforeScenario(pickle)
+		suite.Options.Output.Write([]byte("early"))
+		a.beforeStep(pickle.Steps[0])
+
+		<-timedOut // The step is still running when the probe times out, and ends before the probe is completed
+		a.afterStep(pickle.Steps[0], nil)
+		a.afterScenario(pickle, nil)
+		suite.Options.Output.Write([]byte(" late"))
+		close(stepEnded)
+
+		<-completed // The next scenario starts after the probe is completed
+		late := summary.GetProbeLog(probeName).InitializeAuditor("a late scenario", nil)
+		late.AuditScenarioStep("a late step", "", nil, nil)
+		return 0
+	}
+
+	var results bytes.Buffer
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := runTestSuite(ctx, resultsOutputs{{format: "cucumber", w: &results}}, gd); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("runTestSuite() returned %v, Expected: %v", err, context.DeadlineExceeded)
+	}
+	close(timedOut)
+	<-stepEnded
+	summary.ProbeComplete(probeName)
+	close(completed)
+	<-finished
+
+	if _, found := featureProbes.Load(gd.FeaturePath); found {
+		t.Errorf("Expected the abandoned run to be detached from the probe")
+	}
+	if results.String() != "early" {
+		t.Errorf("Expected results written before the timeout only, but found '%s'", results.String())
+	}
+	probe := summary.GetProbeLog(probeName)
+	if len(probe.Scenarios) != 1 || len(probe.Scenarios[0].Steps) != 0 {
+		t.Errorf("Expected only the scenario started before the timeout, without steps, but found %d scenarios", len(probe.Scenarios))
+	}
+}
@@ -0,0 +1,158 @@
+package probeengine
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/cucumber/gherkin-go/v11"
+	"github.com/cucumber/messages-go/v10"
+
+	"github.com/citihub/probr-sdk/config"
+	"github.com/citihub/probr-sdk/utils"
+)
+
+// ExecutionPlan describes which probes and scenarios would be executed by a ProbeStore with its current tags
+type ExecutionPlan struct {
+	Name   string
+	Tags   string
+	Probes []ProbePlan
+}
+
+// ProbePlan describes whether a probe would be executed and, if not, why
+type ProbePlan struct {
+	Name        string
+	FeaturePath string
+	Included    bool
+	Reason      string `json:",omitempty"`
+	Scenarios   []ScenarioPlan
+}
+
+// ScenarioPlan describes whether a scenario would be executed and, if not, why
+type ScenarioPlan struct {
+	Name     string
+	Tags     []string
+	Included bool
+	Reason   string `json:",omitempty"`
+}
+
+// Plan parses the feature file of every probe in the ProbeStore and evaluates each scenario
+// against the store's tags, without executing anything
+func (ps *ProbeStore) Plan() *ExecutionPlan {
+	plan := &ExecutionPlan{
+		Name: ps.Name,
+		Tags: ps.Tags,
+	}
+	reasons := config.Vars.GetExclusionReasons()
+	for _, name := range ps.probeNames() {
+		p, _ := ps.GetProbe(name)
+		plan.Probes = append(plan.Probes, planProbe(p, reasons))
+	}
+	return plan
+}
+
+func planProbe(p *GodogProbe, reasons map[string]string) ProbePlan {
+	probePlan := ProbePlan{
+		Name:        p.Name,
+		FeaturePath: p.FeaturePath,
+	}
+	if *p.Status == Excluded {
+		probePlan.Reason = "probe is excluded"
+		return probePlan
+	}
+
+	pickles, err := parseFeatureFile(p.FeaturePath)
+	if err != nil {
+		log.Printf("[ERROR] Could not plan probe '%s': %v", p.Name, err)
+		probePlan.Reason = err.Error()
+		return probePlan
+	}
+
+	for _, pickle := range pickles {
+		scenarioPlan := ScenarioPlan{Name: pickle.Name}
+		for _, tag := range pickle.Tags {
+			scenarioPlan.Tags = append(scenarioPlan.Tags, tag.Name)
+		}
+		scenarioPlan.Included, scenarioPlan.Reason = evaluateTags(p.Tags, pickle.Tags, reasons)
+		probePlan.Included = probePlan.Included || scenarioPlan.Included
+		probePlan.Scenarios = append(probePlan.Scenarios, scenarioPlan)
+	}
+	if !probePlan.Included {
+		probePlan.Reason = "no scenarios match tags"
+	}
+	return probePlan
+}
+
+func parseFeatureFile(path string) ([]*messages.Pickle, error) {
+	b, err := utils.ReadStaticFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read feature file '%s': %v", path, err)
+	}
+	newID := (&messages.Incrementing{}).NewId
+	doc, err := gherkin.ParseGherkinDocument(bytes.NewReader(b), newID)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse feature file '%s': %v", path, err)
+	}
+	return gherkin.Pickles(*doc, path, newID), nil
+}
+
+// evaluateTags applies godog's tag filter to the scenario tags. Clauses separated by "&&" must all be met,
+// and a clause is met if any of its comma separated tags are present (or absent, if prefixed with "~").
+// If the scenario is excluded, the reason refers to the first clause that was not met.
+func evaluateTags(filter string, tags []*messages.Pickle_PickleTag, reasons map[string]string) (bool, string) {
+	if strings.TrimSpace(filter) == "" {
+		return true, ""
+	}
+	for _, clause := range strings.Split(filter, "&&") {
+		var met bool
+		for _, tag := range strings.Split(clause, ",") {
+			tag = strings.Replace(strings.TrimSpace(tag), "@", "", -1)
+			if strings.HasPrefix(tag, "~") {
+				met = met || !hasTag(tags, tag[1:])
+			} else if tag != "" {
+				met = met || hasTag(tags, tag)
+			}
+		}
+		if !met {
+			clause = strings.TrimSpace(clause)
+			if reason, found := reasons[strings.TrimPrefix(clause, "~@")]; found {
+				return false, fmt.Sprintf("excluded by config: %s", reason)
+			}
+			return false, fmt.Sprintf("does not match tags '%s'", clause)
+		}
+	}
+	return true, ""
+}
+
+func hasTag(tags []*messages.Pickle_PickleTag, tag string) bool {
+	for _, t := range tags {
+		if strings.Replace(t.Name, "@", "", -1) == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// Print writes the plan to w, formatted as either "table" or "json"
+func (plan *ExecutionPlan) Print(w io.Writer, format string) error {
+	switch format {
+	case "json":
+		_, err := w.Write(utils.JSON(plan))
+		return err
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintf(tw, "Tags: %s\n\n", plan.Tags)
+		fmt.Fprintln(tw, "PROBE\tSCENARIO\tINCLUDED\tREASON")
+		for _, probe := range plan.Probes {
+			fmt.Fprintf(tw, "%s\t\t%t\t%s\n", probe.Name, probe.Included, probe.Reason)
+			for _, scenario := range probe.Scenarios {
+				fmt.Fprintf(tw, "\t%s\t%t\t%s\n", scenario.Name, scenario.Included, scenario.Reason)
+			}
+		}
+		return tw.Flush()
+	}
+	return fmt.Errorf("unknown plan format '%s'; must be 'table' or 'json'", format)
+}
@@ -0,0 +1,84 @@
+package probeengine
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/citihub/probr-sdk/audit"
+	"github.com/citihub/probr-sdk/config"
+)
+
+type planTestProbe struct {
+	TestProbe
+}
+
+// Path presents the path of the test feature file
+func (probe planTestProbe) Path() string {
+	return filepath.Join(testFolder, "Test_plan.feature")
+}
+
+func TestPlan(t *testing.T) {
+	config.Vars.ServicePacks.Kubernetes.Probes = []config.Probe{
+		{
+			Name:      "plan_probe",
+			Scenarios: []config.Scenario{{Name: "excluded_by_config", Excluded: "not applicable to this cluster"}},
+		},
+	}
+	defer func() {
+		config.Vars.ServicePacks.Kubernetes.Probes = nil
+	}()
+
+	tags := "~@probes/kubernetes/plan_probe/excluded_by_config && ~@k-plan-003"
+	summary := audit.NewSummaryState(probeStoreName)
+	ps := NewProbeStore(probeStoreName, tags, &summary)
+	ps.Probes["plan_probe"] = ps.makeGodogProbe(probeStoreName, planTestProbe{TestProbe{name: "plan_probe"}})
+	ps.Probes["missing_probe"] = ps.makeGodogProbe(probeStoreName, TestProbe{name: "missing_probe"})
+	status := Pending
+	for _, p := range ps.Probes {
+		p.Status = &status
+	}
+
+	plan := ps.Plan()
+	if len(plan.Probes) != 2 {
+		t.Fatalf("Expected plan to contain 2 probes, but found %d", len(plan.Probes))
+	}
+
+	missing := plan.Probes[0]
+	if missing.Name != "missing_probe" || missing.Included || missing.Reason == "" {
+		t.Errorf("Expected missing_probe to be excluded with a reason, but found %+v", missing)
+	}
+
+	probe := plan.Probes[1]
+	if !probe.Included || len(probe.Scenarios) != 3 {
+		t.Fatalf("Expected plan_probe to be included with 3 scenarios, but found %+v", probe)
+	}
+	expected := []struct {
+		included bool
+		reason   string
+	}{
+		{included: true, reason: ""},
+		{included: false, reason: "excluded by config: not applicable to this cluster"},
+		{included: false, reason: "does not match tags '~@k-plan-003'"},
+	}
+	for i, scenario := range probe.Scenarios {
+		if scenario.Included != expected[i].included || scenario.Reason != expected[i].reason {
+			t.Errorf("Expected scenario '%s' to have included=%v reason='%s', but found included=%v reason='%s'",
+				scenario.Name, expected[i].included, expected[i].reason, scenario.Included, scenario.Reason)
+		}
+	}
+
+	var b bytes.Buffer
+	if err := plan.Print(&b, "json"); err != nil || !json.Valid(b.Bytes()) {
+		t.Errorf("Expected plan to be printed as valid JSON, but found error: %v", err)
+	}
+	b.Reset()
+	if err := plan.Print(&b, "table"); err != nil || !strings.Contains(b.String(), "This scenario is excluded by tag") {
+		t.Errorf("Expected plan to be printed as a table, but found error: %v", err)
+	}
+	if err := plan.Print(&b, "xml"); err == nil {
+		t.Errorf("Expected an error when printing plan in an unknown format")
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/cucumber/godog"
 
 	sdk "github.com/citihub/probr-sdk"
+	"github.com/citihub/probr-sdk/events"
 	"github.com/citihub/probr-sdk/utils"
 )
 
@@ -22,6 +23,13 @@ type Probe interface {
 	Path() string
 }
 
+// DependentProbe may optionally be implemented by a Probe that should only be executed
+// after the named probes have completed successfully
+type DependentProbe interface {
+	Probe
+	Dependencies() []string
+}
+
 // This is a var-func in order to be able to mock oiginal behavior during testing.
 var cucumberDirFunc = func() string {
 	cucumberDir := filepath.Join(sdk.GlobalConfig.OutputDir(), "cucumber")
@@ -29,9 +37,6 @@ var cucumberDirFunc = func() string {
 	return cucumberDir
 }
 
-// see TestGetOutputPath
-var getTmpFeatureFileFunc = getTmpFeatureFile // See TestGetFeaturePath
-
 // getOutputPath gets the output path for the test based on the output directory
 // plus the test name supplied
 func getOutputPath(t string) (*os.File, error) {
@@ -41,19 +46,66 @@ func getOutputPath(t string) (*os.File, error) {
 	return os.Create(filepath.Join(cucumberDirFunc(), fn))
 }
 
-// GetFilePath parses a list of strings into a standardized file path. The filename should be in the final element of path
+// resultsFileExtensions are the file extensions used for results written in each godog format
+var resultsFileExtensions = map[string]string{
+	"cucumber": ".json",
+	"events":   ".ndjson",
+	"junit":    ".xml",
+	"pretty":   ".txt",
+	"progress": ".txt",
+}
+
+// getResultsOutputPath gets the output path for the test results in the given format. Cucumber results are
+// written to the path given by getOutputPath; other formats include the format name, e.g. "<test>.junit.xml".
+func getResultsOutputPath(t string, format string) (*os.File, error) {
+	if format == "cucumber" {
+		return getOutputPath(t)
+	}
+	ext, found := resultsFileExtensions[format]
+	if !found {
+		ext = ".txt"
+	}
+	return os.Create(filepath.Join(cucumberDirFunc(), t+"."+format+ext))
+}
+
+// GetFilePath parses a list of strings into a standardized file path. The filename should be in the final element of path.
+// Relative paths are read from the layers registered with utils.RegisterStaticFS when the probe is run.
 func GetFilePath(path ...string) (filePath string) {
 	for _, entry := range path {
 		filePath = filepath.Join(filePath, entry)
 	}
+	return filePath
+}
 
-	// Unpacking/copying feature file to tmp location
-	tmpFilePath, err := getTmpFeatureFileFunc(filePath)
+// resolveFeaturePath returns the path godog reads the feature file from. Files provided by a directory layer of the
+// static file system are read by godog in place. godog can only read features from disk, so files that are only
+// available from other layers (e.g. embed.FS) are written to sdk.GlobalConfig.TmpDir; see writeTmpFeatureFile.
+func resolveFeaturePath(featurePath string) (string, error) {
+	if filepath.IsAbs(featurePath) {
+		return featurePath, nil
+	}
+	name := utils.StaticFSPath(featurePath)
+	if diskPath, found := utils.GetStaticFS().DiskPath(name); found {
+		return diskPath, nil
+	}
+	contents, err := utils.GetStaticFS().ReadFile(name)
 	if err != nil {
-		log.Printf("Error unpacking feature file '%v' - Error: %v", filePath, err)
-		return ""
+		return "", fmt.Errorf("could not read feature file '%s': %v", featurePath, err)
 	}
-	return tmpFilePath
+	return writeTmpFeatureFile(featurePath, contents)
+}
+
+// writeTmpFeatureFile writes the contents of a feature file to its path within sdk.GlobalConfig.TmpDir.
+// The file is written on every run, so that it never differs from the static file system.
+func writeTmpFeatureFile(featurePath string, contents []byte) (string, error) {
+	tmpFeaturePath := filepath.Join(sdk.GlobalConfig.TmpDir, featurePath)
+	if err := os.MkdirAll(filepath.Dir(tmpFeaturePath), 0755); err != nil {
+		return "", fmt.Errorf("could not create directory for feature file '%s': %v", featurePath, err)
+	}
+	if err := ioutil.WriteFile(tmpFeaturePath, contents, 0644); err != nil {
+		return "", fmt.Errorf("could not write feature file '%s': %v", featurePath, err)
+	}
+	return tmpFeaturePath, nil
 }
 
 // GetFeaturePath parses a list of strings into a standardized file path for the BDD ".feature" files
@@ -64,62 +116,16 @@ func GetFeaturePath(path ...string) string {
 	return GetFilePath(path...)
 }
 
-// getTmpFeatureFile checks if feature file exists in -tmp- folder.
-// If so returns the file path, otherwise unpacks the original file using pkger and copies it to -tmp- location before returning file path.
-func getTmpFeatureFile(featurePath string) (string, error) {
-
-	tmpFeaturePath := filepath.Join(sdk.GlobalConfig.TmpDir, featurePath)
-
-	// If file already exists return it
-	_, e := os.Stat(tmpFeaturePath)
-	if e == nil {
-		return tmpFeaturePath, nil
-	}
-
-	// If file doesn't exist, extract it from pkger inmemory buffer
-	if os.IsNotExist(e) {
-
-		err := unpackFileAndSave(featurePath, tmpFeaturePath)
-		if err != nil {
-			return "", fmt.Errorf("Error unpacking file: '%v' - Error: %v", featurePath, err)
-		}
-
-		return tmpFeaturePath, err
-	}
-
-	return "", fmt.Errorf("Error getting os stat for tmp file: '%v' - Error: %v", tmpFeaturePath, e)
-}
-
-func unpackFileAndSave(origFilePath string, newFilePath string) error {
-
-	// TODO: This function could be extracted to a separate object i.e: Bundler interface?
-
-	fileBytes, readFileErr := utils.ReadStaticFile(origFilePath) // Read bytes using pkger memory bundle
-	if readFileErr != nil {
-		return fmt.Errorf("Error reading file content: '%v' - Error: %v", origFilePath, readFileErr)
-	}
-
-	createFilePathErr := os.MkdirAll(filepath.Dir(newFilePath), 0755) // Create directory and sub directories for file
-	if createFilePathErr != nil {
-		return fmt.Errorf("Error creating path for file: '%v' - Error: %v", newFilePath, createFilePathErr)
-	}
-
-	writeFileErr := ioutil.WriteFile(newFilePath, fileBytes, 0755) // Save file to new location
-	if writeFileErr != nil {
-		return fmt.Errorf("Error saving file: '%v' - Error: %v", newFilePath, writeFileErr)
-	}
-
-	return nil // File created
-}
-
-// LogScenarioStart logs the name and tags associated with the supplied scenario.
+// LogScenarioStart logs the name and tags associated with the supplied scenario, and publishes a ScenarioStarted event.
 func LogScenarioStart(s *godog.Scenario) {
 	log.Print(scenarioString(true, s))
+	publishScenarioEvent(events.ScenarioStarted, s)
 }
 
-// LogScenarioEnd logs the name and tags associated with the supplied scenario.
+// LogScenarioEnd logs the name and tags associated with the supplied scenario, and publishes a ScenarioFinished event.
 func LogScenarioEnd(s *godog.Scenario) {
 	log.Print(scenarioString(false, s))
+	publishScenarioEvent(events.ScenarioFinished, s)
 }
 
 func scenarioString(st bool, s *godog.Scenario) string {
@@ -1,13 +1,16 @@
 package probeengine
 
 import (
+	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"testing/fstest"
 
 	sdk "github.com/citihub/probr-sdk"
+	"github.com/citihub/probr-sdk/events"
 	"github.com/citihub/probr-sdk/utils"
 	"github.com/cucumber/godog"
 )
@@ -18,7 +21,7 @@ func TestMain(m *testing.M) {
 
 	log.Print("Initializing global test resources")
 
-	f, testFolderErr := filepath.Abs("./testdata") // Need absolute path so that pkger.Open can work
+	f, testFolderErr := filepath.Abs("./testdata") // Need absolute path so that files are read from disk rather than the static file system
 	if testFolderErr != nil {
 		log.Fatalf("Error loading test data folder: %v", testFolderErr)
 	}
@@ -83,16 +86,32 @@ func TestScenarioString(t *testing.T) {
 	}
 }
 
-func TestGetFeaturePath(t *testing.T) {
-	// Faking result for getTmpFeatureFileFunc() to avoid creating -tmp- folder and feature file.
-	getTmpFeatureFileFunc = func(featurePath string) (string, error) {
-		tmpFeaturePath := filepath.Join("tmp", featurePath)
-		return tmpFeaturePath, nil
+func TestLogScenarioStart(t *testing.T) {
+	featurePath := filepath.Join(testFolder, "Test_getTmpFeatureFile.feature")
+	featureProbes.Store(featurePath, &GodogProbe{Name: probeName, Pack: probeStoreName})
+	defer featureProbes.Delete(featurePath)
+
+	var received []events.Event
+	unsubscribe := events.Subscribe(func(e events.Event) {
+		if e.Pack == probeStoreName {
+			received = append(received, e)
+		}
+	})
+	defer unsubscribe()
+
+	gs := &godog.Scenario{Name: "test scenario", Uri: featurePath}
+	LogScenarioStart(gs)
+	LogScenarioEnd(gs)
+
+	if len(received) != 2 || received[0].Type != events.ScenarioStarted || received[1].Type != events.ScenarioFinished {
+		t.Fatalf("Expected ScenarioStarted and ScenarioFinished events, but found %v", received)
 	}
-	defer func() {
-		getTmpFeatureFileFunc = getTmpFeatureFile //Restoring to original function after test
-	}()
+	if received[0].Probe != probeName || received[0].Scenario != gs.Name {
+		t.Errorf("Expected event for scenario '%s' of probe '%s', but found %+v", gs.Name, probeName, received[0])
+	}
+}
 
+func TestGetFeaturePath(t *testing.T) {
 	type args struct {
 		path []string
 	}
@@ -104,7 +123,7 @@ func TestGetFeaturePath(t *testing.T) {
 		{
 			testName:       "GetFeaturePath_WithTwoSubfoldersAndFeatureName_ShouldReturnFeatureFilePath",
 			testArgs:       args{path: []string{"internal", "container_registry_access"}},
-			expectedResult: filepath.Join("tmp", "internal", "container_registry_access", "container_registry_access.feature"), // Using filepath.join() instead of literal string in order to run test in Windows (\\) and Linux (/)
+			expectedResult: filepath.Join("internal", "container_registry_access", "container_registry_access.feature"), // Using filepath.join() instead of literal string in order to run test in Windows (\\) and Linux (/)
 		},
 	}
 	for _, tt := range tests {
@@ -116,83 +135,57 @@ func TestGetFeaturePath(t *testing.T) {
 	}
 }
 
-func Test_getTmpFeatureFile(t *testing.T) {
+func TestResolveFeaturePath(t *testing.T) {
+	featurePath := filepath.Join("embedded", "Test_resolveFeaturePath.feature")
+	overrideDir := filepath.Join(testFolder, utils.RandomString(10))
+	tmpDir := sdk.GlobalConfig.TmpDir
+	sdk.SetTmpDir(filepath.Join(testFolder, utils.RandomString(10)))
 
-	sdk.GlobalConfig.TmpDir = filepath.Join(testFolder, utils.RandomString(10))
+	// Register an in-memory layer, such as a service pack's embed.FS
+	unregister := utils.RegisterStaticFS(fstest.MapFS{
+		"embedded/Test_resolveFeaturePath.feature": &fstest.MapFile{Data: []byte("Feature: embedded")},
+	})
 	defer func() {
-		os.RemoveAll(sdk.GlobalConfig.TmpDir) // Delete test data after tests
+		unregister()
+		utils.SetStaticOverrideDir("")
+		os.RemoveAll(overrideDir) // Delete test data after tests
+		os.RemoveAll(sdk.GlobalConfig.TmpDir)
+		sdk.SetTmpDir(tmpDir)
 	}()
 
-	type args struct {
-		featurePath string
+	// Files only available in memory are written to the tmp directory for godog to read
+	got, err := resolveFeaturePath(featurePath)
+	expected := filepath.Join(sdk.GlobalConfig.TmpDir, featurePath)
+	if contents, _ := ioutil.ReadFile(expected); err != nil || got != expected || string(contents) != "Feature: embedded" {
+		t.Errorf("resolveFeaturePath() = %v, %v; Expected: %v with the embedded contents, but found %q", got, err, expected, contents)
 	}
-	tests := []struct {
-		testName       string
-		testArgs       args
-		expectedResult string
-		expectedErr    bool
-	}{
-		{
-			testName:       "ShouldCreateTmpFolderWithFeatureFile",
-			testArgs:       args{featurePath: filepath.Join("probeengine", "testdata", "Test_getTmpFeatureFile.feature")}, // This cannot be an absolute path, since it will be joined with temp dir
-			expectedResult: filepath.Join(sdk.GlobalConfig.TmpDir, "probeengine", "testdata", "Test_getTmpFeatureFile.feature"),
-			expectedErr:    false,
-		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.testName, func(t *testing.T) {
-			got, err := getTmpFeatureFile(tt.testArgs.featurePath)
-			if (err != nil) != tt.expectedErr {
-				t.Errorf("getTmpFeatureFile() error = %v, expected error: %v", err, tt.expectedErr)
-				return
-			}
-			if got != tt.expectedResult {
-				t.Errorf("getTmpFeatureFile() = %v, expected %v", got, tt.expectedResult)
-			}
-			// Check if file was saved to tmp location
-			_, e := os.Stat(tt.expectedResult)
-			if e != nil {
-				t.Errorf("File not found in tmp location: %v - Error: %v", tt.expectedResult, e)
-			}
-		})
-	}
-}
-
-func Test_unpackFileAndSave(t *testing.T) {
 
-	testTargetDir := filepath.Join(testFolder, utils.RandomString(10))
-	defer func() {
-		os.RemoveAll(testTargetDir) // Delete test data after tests
-	}()
+	// Files in the override directory should be used in place
+	_ = os.MkdirAll(filepath.Join(overrideDir, "embedded"), 0755)
+	_ = ioutil.WriteFile(filepath.Join(overrideDir, featurePath), []byte("Feature: override"), 0644)
+	utils.SetStaticOverrideDir(overrideDir)
+	got, err = resolveFeaturePath(featurePath)
+	expected = filepath.Join(overrideDir, featurePath)
+	if err != nil || got != expected {
+		t.Errorf("resolveFeaturePath() = %v, %v; Expected: %v", got, err, expected)
+	}
+	utils.SetStaticOverrideDir("")
 
-	type args struct {
-		origFilePath string
-		newFilePath  string
+	// Files in the working directory, the default layer, should be used in place
+	workingDirPath := filepath.Join("testdata", "Test_getTmpFeatureFile.feature")
+	if got, err = resolveFeaturePath(workingDirPath); err != nil || got != workingDirPath {
+		t.Errorf("resolveFeaturePath() = %v, %v; Expected: %v", got, err, workingDirPath)
 	}
-	tests := []struct {
-		testName    string
-		testArgs    args
-		expectedErr bool
-	}{
-		{
-			testName: "ShouldCreateFileInNewLocation",
-			testArgs: args{
-				origFilePath: filepath.Join(testFolder, "Test_unpackFileAndSave.feature"),
-				newFilePath:  filepath.Join(testTargetDir, "Test_unpackFileAndSave.feature"),
-			},
-			expectedErr: false,
-		},
+
+	// Absolute paths should be used as provided
+	absolutePath := filepath.Join(testFolder, "Test_getTmpFeatureFile.feature")
+	if got, _ = resolveFeaturePath(absolutePath); got != absolutePath {
+		t.Errorf("resolveFeaturePath() = %v, Expected: %v", got, absolutePath)
 	}
-	for _, tt := range tests {
-		t.Run(tt.testName, func(t *testing.T) {
-			if err := unpackFileAndSave(tt.testArgs.origFilePath, tt.testArgs.newFilePath); (err != nil) != tt.expectedErr {
-				t.Errorf("unpackFileAndSave() error = %v, expected error: %v", err, tt.expectedErr)
-			}
-			// Check if file was saved to tmp location
-			_, e := os.Stat(tt.testArgs.newFilePath)
-			if e != nil {
-				t.Errorf("File not found in tmp location: %v - Error: %v", tt.testArgs.newFilePath, e)
-			}
-		})
+
+	// Unregistered layers are no longer read
+	unregister()
+	if _, err = resolveFeaturePath(featurePath); err == nil {
+		t.Errorf("Expected resolveFeaturePath() to fail once the layer providing the file is unregistered")
 	}
 }
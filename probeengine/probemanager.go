@@ -4,11 +4,21 @@
 package probeengine
 
 import (
+	"context"
 	"errors"
 	"log"
+	"os"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	audit "github.com/citihub/probr-sdk/audit"
+	"github.com/citihub/probr-sdk/config"
+	"github.com/citihub/probr-sdk/events"
+	"github.com/citihub/probr-sdk/history"
+	"github.com/citihub/probr-sdk/report"
+	"github.com/citihub/probr-sdk/retention"
 )
 
 // ProbeStatus type describes the status of the test, e.g. Pending, Running, CompleteSuccess, CompleteFail and Error
@@ -22,55 +32,103 @@ const (
 	CompleteFail
 	Error
 	Excluded
+	DependencyFailed
 )
 
 func (s ProbeStatus) String() string {
-	return [...]string{"Pending", "Running", "CompleteSuccess", "CompleteFail", "Error", "Excluded"}[s]
+	return [...]string{"Pending", "Running", "CompleteSuccess", "CompleteFail", "Error", "Excluded", "DependencyFailed"}[s]
 }
 
 // ProbeStore maintains a collection of probes to be run and their status.  FailedProbes is an explicit
-// collection of failed probes.
+// collection of failed probes. MaxConcurrentProbes limits the number of probes executed at the same time.
+// ProbeTimeout and RunTimeout limit the duration of each probe and of all probes respectively; zero means no limit.
+// OutputType selects whether probe results are written to file, held in memory, or both.
+// If DryRun is set to "table" or "json", RunAllProbes prints the execution plan in that format instead of running probes.
+// If Resume is set, probes completed according to the checkpoint of an earlier run are not run again.
+// If ShardTotal is greater than one, only the probes assigned to ShardIndex are run; see shardAssignments.
 type ProbeStore struct {
-	Name         string
-	Probes       map[string]*GodogProbe
-	FailedProbes map[ProbeStatus]*GodogProbe
-	Lock         sync.RWMutex
-	Summary      *audit.SummaryState
-	Tags         string
+	Name                string
+	Probes              map[string]*GodogProbe
+	FailedProbes        map[ProbeStatus]*GodogProbe
+	Lock                sync.RWMutex
+	Summary             *audit.SummaryState
+	Tags                string
+	MaxConcurrentProbes int
+	ProbeTimeout        time.Duration
+	RunTimeout          time.Duration
+	OutputType          string
+	DryRun              string
+	Resume              bool
+	ShardIndex          int
+	ShardTotal          int
+	ShardDurations      map[string]time.Duration // Durations of previous runs of each probe, used to balance shards
+	sharded             bool
 }
 
-// NewProbeStore creates a new object to store GodogProbes
+// pruneOnStart applies the retention policy to the output directory once, before the first ProbeStore runs any probes
+var pruneOnStart sync.Once
+
+// NewProbeStore creates a new object to store GodogProbes.
 func NewProbeStore(name string, tags string, summaryState *audit.SummaryState) *ProbeStore {
+	shardIndex, shardTotal := config.Vars.GetShard()
 	return &ProbeStore{
-		Name:    name,
-		Probes:  make(map[string]*GodogProbe),
-		Summary: summaryState,
-		Tags:    tags,
+		Name:                name,
+		Probes:              make(map[string]*GodogProbe),
+		Summary:             summaryState,
+		Tags:                tags,
+		MaxConcurrentProbes: config.Vars.GetMaxConcurrentProbes(),
+		ProbeTimeout:        config.Vars.GetProbeTimeout(),
+		RunTimeout:          config.Vars.GetRunTimeout(),
+		OutputType:          config.Vars.OutputType,
+		DryRun:              config.Vars.DryRun,
+		Resume:              config.Vars.Resume,
+		ShardIndex:          shardIndex,
+		ShardTotal:          shardTotal,
+		ShardDurations:      config.Vars.GetShardDurations(),
 	}
 }
 
-// RunAllProbes retrieves and executes all probes that have been included
-func (ps *ProbeStore) RunAllProbes(probes []Probe) (int, error) {
+// RunAllProbes retrieves and executes all probes that have been included, then writes an HTML report of the run; see report.Write.
+// If RecordHistory is set, the results are also recorded in the results history; see history.Record.
+// The returned status is the exit code of the run according to the configured exit policy (see audit.ExitPolicy),
+// or audit.ExitError if the probes could not be executed; it is intended to be used as the exit code of the process.
+func (ps *ProbeStore) RunAllProbes(ctx context.Context, probes []Probe) (int, error) {
 	for _, probe := range probes {
 		ps.AddProbe(probe)
 	}
 
-	s, err := ps.ExecAllProbes() // Executes all added (queued) tests
-	return s, err
+	ps.applyShard()
+	if ps.DryRun != "" {
+		return 0, ps.Plan().Print(os.Stdout, ps.DryRun)
+	}
+
+	_, err := ps.ExecAllProbes(ctx) // Executes all added (queued) tests
+	if err != nil {
+		return audit.ExitError, err
+	}
+	if reportErr := report.Write(ps.Summary, ps.Name); reportErr != nil {
+		log.Printf("[ERROR] HTML report was not written: %v", reportErr)
+	}
+	if strings.ToLower(config.Vars.RecordHistory) == "true" {
+		history.Record(ps.Name, ps.Summary)
+	}
+	return ps.Summary.ExitCode(), nil
 }
 
 // AddProbe provided GodogProbe to the ProbeStore.
-func (ps *ProbeStore) AddProbe(probe Probe) {
+func (ps *ProbeStore) AddProbe(preParsedProbe Probe) {
 	ps.Lock.Lock()
-	defer ps.Lock.Unlock()
 
 	probe := ps.makeGodogProbe(ps.Name, preParsedProbe)
 	status := Pending
 	probe.Status = &status
 	ps.Probes[probe.Name] = probe
 
-	ps.Summary.GetProbeLog(probe.Name).Result = probe.Status.String()
+	ps.Summary.LogProbeResult(probe.Name, probe.Status.String())
 	ps.Summary.LogProbeMeta(probe.Name, "group", probe.Pack)
+	ps.Lock.Unlock()
+
+	ps.publishProbeEvent(events.ProbeQueued, probe.Name)
 }
 
 // GetProbe returns the test identified by the given name.
@@ -87,39 +145,137 @@ func (ps *ProbeStore) GetProbe(name string) (*GodogProbe, error) {
 	return p, nil
 }
 
-// ExecProbe executes the test identified by the specified name.
-func (ps *ProbeStore) ExecProbe(name string) (int, error) {
+// ExecProbe executes the test identified by the specified name, applying ProbeTimeout if set.
+func (ps *ProbeStore) ExecProbe(ctx context.Context, name string) (int, error) {
 	p, err := ps.GetProbe(name)
 	log.Printf("Executing Probe: %v", p)
 	if err != nil {
 		return 1, err // Failure
 	}
-	if p.Status.String() != Excluded.String() {
-		return ps.RunProbe(p) // Return test results
+	if p.Status.String() == Excluded.String() {
+		return 0, nil // Succeed if test is excluded
 	}
-	return 0, nil // Succeed if test is excluded
+	if ps.ProbeTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, ps.ProbeTimeout)
+		defer cancel()
+	}
+	return ps.RunProbe(ctx, p) // Return test results
 }
 
 // ExecAllProbes executes all tests that are present in the ProbeStore.
-func (ps *ProbeStore) ExecAllProbes() (int, error) {
-	status := 0
-	var err error
+// Probes are distributed across a pool of workers sized by MaxConcurrentProbes;
+// the returned status is the highest status returned by any probe, as with a serial run.
+// Probes still queued when ctx is done, or RunTimeout elapses, are marked as Error without being run.
+// Probes are queued in dependency order, and dependents of probes that did not succeed are not run.
+// Progress is checkpointed as each probe completes; see Checkpoint. The checkpoint is removed once every probe has run
+// to completion, so only an interrupted run can be resumed.
+// The status does not distinguish skipped probes or unmet scenarios from passes; RunAllProbes applies the configured
+// exit policy instead.
+// The first call applies the retention policy if Retention.PruneOnStart is set; see retention.PruneOnStart. A dry run
+// of RunAllProbes doesn't execute probes, so never prunes.
+func (ps *ProbeStore) ExecAllProbes(ctx context.Context) (int, error) {
+	var (
+		status int
+		err    error
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+	)
 
-	for name := range ps.Probes {
-		st, err := ps.ExecProbe(name)
-		ps.Summary.ProbeComplete(name)
-		if err != nil {
-			//log but continue with remaining probe
-			log.Printf("[ERROR] error executing probe: %v", err)
-		}
-		if st > status {
-			status = st
+	pruneOnStart.Do(retention.PruneOnStart)
+
+	ps.applyShard()
+	names, err := ps.executionOrder()
+	if err != nil {
+		log.Printf("[ERROR] Probes could not be executed: %v", err)
+		return 2, err
+	}
+	var resumed map[string]ProbeStatus
+	if ps.Resume {
+		resumed = ps.resumeFromCheckpoint()
+	}
+	ps.Lock.Lock()
+	ps.writeCheckpoint()
+	ps.Lock.Unlock()
+
+	queue := make(chan string, len(names))
+	done := make(map[string]chan struct{}) // Closed when the named probe has completed
+	for _, name := range names {
+		done[name] = make(chan struct{})
+		if st, ok := resumed[name]; ok {
+			close(done[name])
+			if st == CompleteFail {
+				status = 1
+			}
+			continue
 		}
+		queue <- name
+	}
+	close(queue)
+
+	workers := ps.MaxConcurrentProbes
+	if workers < 1 {
+		workers = 1
 	}
+	if workers > len(queue) {
+		workers = len(queue)
+	}
+	log.Printf("[DEBUG] Executing %d probes using %d workers", len(queue), workers)
+
+	if ps.RunTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, ps.RunTimeout)
+		defer cancel()
+	}
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for name := range queue {
+				st, probeErr := ps.execProbeAfterDependencies(ctx, name, done)
+
+				ps.Lock.Lock()
+				ps.Summary.ProbeComplete(name)
+				ps.writeCheckpoint()
+				ps.Lock.Unlock()
+				ps.publishProbeEvent(events.ProbeFinished, name)
+				close(done[name])
+
+				if probeErr != nil {
+					//log but continue with remaining probe
+					log.Printf("[ERROR] error executing probe: %v", probeErr)
+				}
+				mu.Lock()
+				if st > status {
+					status = st
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	ps.Lock.Lock()
+	ps.finishCheckpoint()
+	ps.Lock.Unlock()
 	ps.Summary.SetProbrStatus()
 	return status, err
 }
 
+// probeNames returns the names of all probes in the store, sorted to keep execution order predictable
+func (ps *ProbeStore) probeNames() []string {
+	ps.Lock.RLock()
+	defer ps.Lock.RUnlock()
+
+	var names []string
+	for name := range ps.Probes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 func (ps *ProbeStore) makeGodogProbe(pack string, probe Probe) *GodogProbe {
 	return &GodogProbe{
 		Name:                probe.Name(),
@@ -128,5 +284,8 @@ func (ps *ProbeStore) makeGodogProbe(pack string, probe Probe) *GodogProbe {
 		ScenarioInitializer: probe.ScenarioInitialize,
 		FeaturePath:         probe.Path(),
 		Tags:                ps.Tags,
+		OutputType:          ps.OutputType,
+		Dependencies:        probeDependencies(probe),
+		summary:             ps.Summary,
 	}
 }
@@ -1,7 +1,27 @@
 package probeengine
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	sdk "github.com/citihub/probr-sdk"
+	"github.com/citihub/probr-sdk/audit"
+	"github.com/citihub/probr-sdk/config"
+	"github.com/citihub/probr-sdk/events"
+	"github.com/citihub/probr-sdk/history"
+	"github.com/citihub/probr-sdk/retention"
+	"github.com/citihub/probr-sdk/utils"
 	"github.com/cucumber/godog"
+	"github.com/cucumber/messages-go/v10"
 )
 
 const (
@@ -32,4 +52,495 @@ func (probe TestProbe) ProbeInitialize(ctx *godog.TestSuiteContext) {
 func (probe TestProbe) ScenarioInitialize(ctx *godog.ScenarioContext) {
 }
 
-// TODO: Add tests for ProbeStore
+func TestExecAllProbes(t *testing.T) {
+	config.Vars.WriteDirectory = filepath.Join(testFolder, utils.RandomString(10))
+	defer func() {
+		os.RemoveAll(config.Vars.WriteDirectory) // Delete test data after tests
+		config.Vars.WriteDirectory = ""
+		probeHandlerFunc = GodogProbeHandler // Restoring to original function after test
+	}()
+
+	// Probes audit a scenario, which fails in "failing_probe" and passes otherwise
+	var mu sync.Mutex
+	running, maxRunning := 0, 0
+	probeHandlerFunc = func(ctx context.Context, probe *GodogProbe) (int, *bytes.Buffer, error) {
+		mu.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mu.Unlock()
+
+		var err error
+		if probe.Name == "failing_probe" {
+			err = errors.New("pod was created")
+		}
+		scenario := probe.summary.GetProbeLog(probe.Name).InitializeAuditor("scenario", []*messages.Pickle_PickleTag{})
+		scenario.AuditScenarioStep("a cluster exists", "", nil, nil)
+		time.Sleep(10 * time.Millisecond)
+		scenario.AuditScenarioStep("the pod is denied", "", nil, err)
+
+		mu.Lock()
+		running--
+		mu.Unlock()
+		if err != nil {
+			return 1, nil, nil
+		}
+		return 0, nil, nil
+	}
+
+	tests := []struct {
+		testName       string
+		maxConcurrent  int
+		expectedStatus int
+	}{
+		{
+			testName:       "ExecAllProbes_WithSingleWorker_ShouldRunSerially",
+			maxConcurrent:  1,
+			expectedStatus: 1,
+		},
+		{
+			testName:       "ExecAllProbes_WithWorkerPool_ShouldRunConcurrently",
+			maxConcurrent:  4,
+			expectedStatus: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			maxRunning = 0
+			summary := audit.NewSummaryState(probeStoreName)
+			ps := NewProbeStore(probeStoreName, "", &summary)
+			ps.MaxConcurrentProbes = tt.maxConcurrent
+			for i := 0; i < 8; i++ {
+				ps.AddProbe(TestProbe{name: fmt.Sprintf("%s_%d", probeName, i)})
+			}
+			ps.AddProbe(TestProbe{name: "failing_probe"})
+
+			status, err := ps.ExecAllProbes(context.Background())
+			if err != nil {
+				t.Errorf("ExecAllProbes() returned unexpected error: %v", err)
+			}
+			if status != tt.expectedStatus {
+				t.Errorf("ExecAllProbes() = %v, Expected: %v", status, tt.expectedStatus)
+			}
+			if maxRunning > tt.maxConcurrent {
+				t.Errorf("Expected no more than %d probes to run at once, but found %d", tt.maxConcurrent, maxRunning)
+			}
+			if tt.maxConcurrent > 1 && maxRunning < 2 {
+				t.Errorf("Expected probes to run concurrently, but found %d running at once", maxRunning)
+			}
+			for name, probe := range ps.Probes {
+				expectedStatus, expectedResult := CompleteSuccess, "Success"
+				if name == "failing_probe" {
+					expectedStatus, expectedResult = CompleteFail, "Failed"
+				}
+				if *probe.Status != expectedStatus {
+					t.Errorf("Expected %s to have status %s, but found %s", name, expectedStatus, probe.Status)
+				}
+				if summary.Probes[name].Result != expectedResult {
+					t.Errorf("Expected %s to have audit result '%s', but found '%s'", name, expectedResult, summary.Probes[name].Result)
+				}
+			}
+			if len(summary.Probes) != 9 || summary.ProbesPassed != 8 || summary.ProbesFailed != 1 || summary.ProbesSkipped != 0 {
+				t.Errorf("Expected 8 passed and 1 failed probe in the summary, but found %d probes (%d passed, %d failed, %d skipped)",
+					len(summary.Probes), summary.ProbesPassed, summary.ProbesFailed, summary.ProbesSkipped)
+			}
+		})
+	}
+}
+
+func TestExecAllProbes_Timeout(t *testing.T) {
+	config.Vars.WriteDirectory = filepath.Join(testFolder, utils.RandomString(10))
+	defer func() {
+		os.RemoveAll(config.Vars.WriteDirectory) // Delete test data after tests
+		config.Vars.WriteDirectory = ""
+		probeHandlerFunc = GodogProbeHandler // Restoring to original function after test
+	}()
+
+	// Probes named "hanging_probe" never complete unless their context is done
+	probeHandlerFunc = func(ctx context.Context, probe *GodogProbe) (int, *bytes.Buffer, error) {
+		if probe.Name != "hanging_probe" {
+			return 0, nil, nil
+		}
+		<-ctx.Done()
+		return 2, nil, fmt.Errorf("probe '%s' did not complete: %w", probe.Name, ctx.Err())
+	}
+
+	tests := []struct {
+		testName     string
+		probeTimeout time.Duration
+		runTimeout   time.Duration
+	}{
+		{
+			testName:     "ExecAllProbes_WithProbeTimeout_ShouldMarkHangingProbeAsError",
+			probeTimeout: 20 * time.Millisecond,
+		},
+		{
+			testName:   "ExecAllProbes_WithRunTimeout_ShouldMarkHangingProbeAsError",
+			runTimeout: 20 * time.Millisecond,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			summary := audit.NewSummaryState(probeStoreName)
+			ps := NewProbeStore(probeStoreName, "", &summary)
+			ps.MaxConcurrentProbes = 2
+			ps.ProbeTimeout = tt.probeTimeout
+			ps.RunTimeout = tt.runTimeout
+			ps.AddProbe(TestProbe{name: probeName})
+			ps.AddProbe(TestProbe{name: "hanging_probe"})
+
+			status, _ := ps.ExecAllProbes(context.Background())
+			if status != 2 {
+				t.Errorf("ExecAllProbes() = %v, Expected: %v", status, 2)
+			}
+			if *ps.Probes["hanging_probe"].Status != Error {
+				t.Errorf("Expected hanging_probe to have status %s, but found %s", Error, ps.Probes["hanging_probe"].Status)
+			}
+			if *ps.Probes[probeName].Status != CompleteSuccess {
+				t.Errorf("Expected %s to have status %s, but found %s", probeName, CompleteSuccess, ps.Probes[probeName].Status)
+			}
+			if summary.Probes["hanging_probe"].Result != "Error" || summary.Probes["hanging_probe"].Error == "" {
+				t.Errorf("Expected timeout to be recorded in the audit entry for hanging_probe, but found: %+v", summary.Probes["hanging_probe"])
+			}
+		})
+	}
+}
+
+type dependentTestProbe struct {
+	TestProbe
+	dependencies []string
+}
+
+// Dependencies presents the names of the probes that must succeed before this probe is run
+func (probe dependentTestProbe) Dependencies() []string {
+	return probe.dependencies
+}
+
+func TestExecAllProbes_Dependencies(t *testing.T) {
+	config.Vars.WriteDirectory = filepath.Join(testFolder, utils.RandomString(10))
+	defer func() {
+		os.RemoveAll(config.Vars.WriteDirectory) // Delete test data after tests
+		config.Vars.WriteDirectory = ""
+		probeHandlerFunc = GodogProbeHandler // Restoring to original function after test
+	}()
+
+	var mu sync.Mutex
+	var executed []string
+	probeHandlerFunc = func(ctx context.Context, probe *GodogProbe) (int, *bytes.Buffer, error) {
+		time.Sleep(5 * time.Millisecond)
+		mu.Lock()
+		executed = append(executed, probe.Name)
+		mu.Unlock()
+		if probe.Name == "failing_probe" {
+			return 1, nil, nil
+		}
+		return 0, nil, nil
+	}
+
+	summary := audit.NewSummaryState(probeStoreName)
+	ps := NewProbeStore(probeStoreName, "", &summary)
+	ps.MaxConcurrentProbes = 4
+	ps.AddProbe(dependentTestProbe{TestProbe{name: "a_identity_probe"}, []string{"z_pod_probe"}})
+	ps.AddProbe(dependentTestProbe{TestProbe{name: "b_after_identity_probe"}, []string{"a_identity_probe"}})
+	ps.AddProbe(TestProbe{name: "z_pod_probe"})
+	ps.AddProbe(TestProbe{name: "failing_probe"})
+	ps.AddProbe(dependentTestProbe{TestProbe{name: "c_after_failure_probe"}, []string{"failing_probe"}})
+	ps.AddProbe(dependentTestProbe{TestProbe{name: "d_transitive_probe"}, []string{"c_after_failure_probe"}})
+
+	status, err := ps.ExecAllProbes(context.Background())
+	if err != nil {
+		t.Errorf("ExecAllProbes() returned unexpected error: %v", err)
+	}
+	if status != 1 {
+		t.Errorf("ExecAllProbes() = %v, Expected: %v", status, 1)
+	}
+
+	position := make(map[string]int)
+	for i, name := range executed {
+		position[name] = i
+	}
+	if position["z_pod_probe"] > position["a_identity_probe"] || position["a_identity_probe"] > position["b_after_identity_probe"] {
+		t.Errorf("Expected probes to be executed after their dependencies, but found order %v", executed)
+	}
+	for _, name := range []string{"c_after_failure_probe", "d_transitive_probe"} {
+		if _, found := position[name]; found {
+			t.Errorf("Expected %s not to be executed after its dependency failed", name)
+		}
+		if *ps.Probes[name].Status != DependencyFailed {
+			t.Errorf("Expected %s to have status %s, but found %s", name, DependencyFailed, ps.Probes[name].Status)
+		}
+		if summary.Probes[name].Result != "Dependency Failed" {
+			t.Errorf("Expected %s to have audit result 'Dependency Failed', but found '%s'", name, summary.Probes[name].Result)
+		}
+	}
+	if summary.ProbesDependencyFailed != 2 {
+		t.Errorf("Expected SummaryState.ProbesDependencyFailed to be 2, but found %d", summary.ProbesDependencyFailed)
+	}
+}
+
+func TestExecutionOrder(t *testing.T) {
+	tests := []struct {
+		testName      string
+		probes        []Probe
+		expectedOrder []string
+		expectedErr   bool
+	}{
+		{
+			testName: "executionOrder_WithDependencies_ShouldSortDependenciesFirst",
+			probes: []Probe{
+				dependentTestProbe{TestProbe{name: "a"}, []string{"c"}},
+				dependentTestProbe{TestProbe{name: "b"}, []string{"a", "c"}},
+				TestProbe{name: "c"},
+				TestProbe{name: "d"},
+			},
+			expectedOrder: []string{"c", "a", "b", "d"},
+		},
+		{
+			testName: "executionOrder_WithCycle_ShouldReturnError",
+			probes: []Probe{
+				dependentTestProbe{TestProbe{name: "a"}, []string{"b"}},
+				dependentTestProbe{TestProbe{name: "b"}, []string{"a"}},
+				TestProbe{name: "c"},
+			},
+			expectedErr: true,
+		},
+		{
+			testName: "executionOrder_WithUnknownDependency_ShouldReturnError",
+			probes: []Probe{
+				dependentTestProbe{TestProbe{name: "a"}, []string{"missing"}},
+			},
+			expectedErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			summary := audit.NewSummaryState(probeStoreName)
+			ps := NewProbeStore(probeStoreName, "", &summary)
+			for _, probe := range tt.probes {
+				ps.Probes[probe.Name()] = ps.makeGodogProbe(probeStoreName, probe)
+			}
+			got, err := ps.executionOrder()
+			if (err != nil) != tt.expectedErr {
+				t.Errorf("executionOrder() error = %v, expected error: %v", err, tt.expectedErr)
+			}
+			if !reflect.DeepEqual(got, tt.expectedOrder) {
+				t.Errorf("executionOrder() = %v, Expected: %v", got, tt.expectedOrder)
+			}
+		})
+	}
+}
+
+func TestExecAllProbes_Events(t *testing.T) {
+	config.Vars.WriteDirectory = filepath.Join(testFolder, utils.RandomString(10))
+	defer func() {
+		os.RemoveAll(config.Vars.WriteDirectory) // Delete test data after tests
+		config.Vars.WriteDirectory = ""
+		probeHandlerFunc = GodogProbeHandler // Restoring to original function after test
+	}()
+	probeHandlerFunc = func(ctx context.Context, probe *GodogProbe) (int, *bytes.Buffer, error) {
+		return 0, nil, nil
+	}
+
+	var mu sync.Mutex
+	var received []events.Event
+	unsubscribe := events.Subscribe(func(e events.Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		if e.Pack == probeStoreName {
+			received = append(received, e)
+		}
+	})
+	defer unsubscribe()
+
+	summary := audit.NewSummaryState(probeStoreName)
+	ps := NewProbeStore(probeStoreName, "", &summary)
+	ps.AddProbe(TestProbe{name: probeName})
+	ps.ExecAllProbes(context.Background())
+
+	expected := []struct {
+		eventType events.Type
+		status    ProbeStatus
+	}{
+		{eventType: events.ProbeQueued, status: Pending},
+		{eventType: events.ProbeStarted, status: Running},
+		{eventType: events.ProbeFinished, status: CompleteSuccess},
+	}
+	if len(received) != len(expected) {
+		t.Fatalf("Expected %d events, but found %d: %v", len(expected), len(received), received)
+	}
+	for i, e := range received {
+		if e.Type != expected[i].eventType || e.Status != expected[i].status.String() || e.Probe != probeName {
+			t.Errorf("Expected event %d to be %s for %s with status %s, but found %+v", i, expected[i].eventType, probeName, expected[i].status, e)
+		}
+	}
+}
+
+func TestExecAllProbes_WithPruneOnStart_ShouldPruneOnce(t *testing.T) {
+	baseDirectory := filepath.Join(testFolder, utils.RandomString(10))
+	installDir := sdk.GlobalConfig.InstallDir
+	sdk.GlobalConfig.InstallDir = baseDirectory
+	config.Vars.WriteDirectory = filepath.Join(baseDirectory, "write")
+	config.Vars.Retention = config.Retention{KeepRuns: "1", PruneOnStart: "true"}
+	pruneOnStart = sync.Once{}
+	defer func() {
+		os.RemoveAll(baseDirectory) // Delete test data after tests
+		sdk.GlobalConfig.InstallDir = installDir
+		config.Vars.WriteDirectory = ""
+		config.Vars.Retention = config.Retention{}
+	}()
+
+	root := retention.OutputRoot()
+	createRun := func(name string, age time.Duration) string {
+		dir := filepath.Join(root, "2021-03-01", name)
+		path := filepath.Join(dir, "summary.json")
+		_ = os.MkdirAll(dir, 0755)
+		_ = ioutil.WriteFile(path, []byte("{}"), 0644)
+		modified := time.Now().Add(-age)
+		for _, p := range []string{path, dir} {
+			_ = os.Chtimes(p, modified, modified)
+		}
+		return dir
+	}
+	older, newer := createRun("older", 48*time.Hour), createRun("newer", 24*time.Hour)
+
+	summary := audit.NewSummaryState(probeStoreName)
+	ps := NewProbeStore(probeStoreName, "", &summary)
+	ps.DryRun = "json"
+	if _, err := ps.RunAllProbes(context.Background(), nil); err != nil {
+		t.Fatalf("RunAllProbes() returned unexpected error: %v", err)
+	}
+	if _, err := os.Stat(older); err != nil {
+		t.Errorf("Expected no runs to be pruned by a dry run: %v", err)
+	}
+
+	ps = NewProbeStore(probeStoreName, "", &summary)
+	ps.ExecAllProbes(context.Background())
+	if _, err := os.Stat(older); !os.IsNotExist(err) {
+		t.Errorf("Expected the oldest run to be pruned when probes are first executed")
+	}
+	if _, err := os.Stat(newer); err != nil {
+		t.Errorf("Expected the most recent run to be kept: %v", err)
+	}
+
+	createRun("latest", 0)
+	ps.ExecAllProbes(context.Background())
+	if _, err := os.Stat(newer); err != nil {
+		t.Errorf("Expected runs to be pruned only when probes are first executed: %v", err)
+	}
+}
+
+func TestRunAllProbes_WithRecordHistory_ShouldRecordRun(t *testing.T) {
+	baseDirectory := filepath.Join(testFolder, utils.RandomString(10))
+	installDir := sdk.GlobalConfig.InstallDir
+	sdk.GlobalConfig.InstallDir = baseDirectory
+	config.Vars.WriteDirectory = filepath.Join(baseDirectory, "write")
+	defer func() {
+		os.RemoveAll(baseDirectory) // Delete test data after tests
+		sdk.GlobalConfig.InstallDir = installDir
+		config.Vars.WriteDirectory = ""
+		config.Vars.RecordHistory = ""
+		probeHandlerFunc = GodogProbeHandler // Restoring to original function after test
+	}()
+	probeHandlerFunc = func(ctx context.Context, probe *GodogProbe) (int, *bytes.Buffer, error) {
+		return 0, nil, nil
+	}
+
+	for _, recordHistory := range []string{"false", "true"} {
+		config.Vars.RecordHistory = recordHistory
+		summary := audit.NewSummaryState(probeStoreName)
+		ps := NewProbeStore(probeStoreName, "", &summary)
+		if _, err := ps.RunAllProbes(context.Background(), []Probe{TestProbe{name: probeName}}); err != nil {
+			t.Fatalf("RunAllProbes() returned unexpected error: %v", err)
+		}
+	}
+
+	store, err := history.Open(history.DefaultDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	runs, err := store.Runs(probeStoreName)
+	if err != nil || len(runs) != 1 || len(runs[0].Probes) != 1 || runs[0].Probes[0].Name != probeName {
+		t.Errorf("Expected only the run with RecordHistory set to be recorded, but found %d runs (error: %v)", len(runs), err)
+	}
+}
+
+func TestRunAllProbes_ShouldReturnExitPolicyCode(t *testing.T) {
+	baseDirectory := filepath.Join(testFolder, utils.RandomString(10))
+	installDir := sdk.GlobalConfig.InstallDir
+	sdk.GlobalConfig.InstallDir = baseDirectory // The report is written to the run directory
+	config.Vars.WriteDirectory = filepath.Join(baseDirectory, "write")
+	defer func() {
+		os.RemoveAll(baseDirectory) // Delete test data after tests
+		sdk.GlobalConfig.InstallDir = installDir
+		config.Vars.WriteDirectory = ""
+		config.Vars.ExitPolicy = config.ExitPolicy{}
+		probeHandlerFunc = GodogProbeHandler // Restoring to original function after test
+	}()
+
+	// Probes audit a scenario that passes or fails according to their name; "skipped_probe" runs no scenarios
+	probeHandlerFunc = func(ctx context.Context, probe *GodogProbe) (int, *bytes.Buffer, error) {
+		if probe.Name == "skipped_probe" {
+			return 0, nil, nil
+		}
+		var err error
+		if probe.Name == "failing_probe" {
+			err = errors.New("failed")
+		}
+		scenario := probe.summary.GetProbeLog(probe.Name).InitializeAuditor("scenario", []*messages.Pickle_PickleTag{})
+		scenario.AuditScenarioStep("a given", "", nil, nil)
+		scenario.AuditScenarioStep("a step", "", nil, err)
+		if err != nil {
+			return 1, nil, nil
+		}
+		return 0, nil, nil
+	}
+
+	tests := []struct {
+		testName     string
+		policy       config.ExitPolicy
+		probes       []Probe
+		expectedCode int
+		expectedErr  bool
+	}{
+		{
+			testName:     "RunAllProbes_WithPassingProbes_ShouldReturnPassed",
+			probes:       []Probe{TestProbe{name: "passing_probe"}, TestProbe{name: "skipped_probe"}},
+			expectedCode: audit.ExitPassed,
+		},
+		{
+			testName:     "RunAllProbes_WithFailingProbe_ShouldReturnFailed",
+			probes:       []Probe{TestProbe{name: "passing_probe"}, TestProbe{name: "failing_probe"}},
+			expectedCode: audit.ExitFailed,
+		},
+		{
+			testName:     "RunAllProbes_WithSkippedProbeAndFailOnSkipped_ShouldReturnSkippedOrUnmet",
+			policy:       config.ExitPolicy{FailOnSkipped: "true"},
+			probes:       []Probe{TestProbe{name: "passing_probe"}, TestProbe{name: "skipped_probe"}},
+			expectedCode: audit.ExitSkippedOrUnmet,
+		},
+		{
+			testName: "RunAllProbes_WithDependencyCycle_ShouldReturnError",
+			probes: []Probe{
+				dependentTestProbe{TestProbe{name: "a"}, []string{"b"}},
+				dependentTestProbe{TestProbe{name: "b"}, []string{"a"}},
+			},
+			expectedCode: audit.ExitError,
+			expectedErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			config.Vars.ExitPolicy = tt.policy
+			summary := audit.NewSummaryState(probeStoreName)
+			ps := NewProbeStore(probeStoreName, "", &summary)
+			code, err := ps.RunAllProbes(context.Background(), tt.probes)
+			if (err != nil) != tt.expectedErr {
+				t.Errorf("RunAllProbes() error = %v, expected error: %v", err, tt.expectedErr)
+			}
+			if code != tt.expectedCode {
+				t.Errorf("RunAllProbes() = %d, Expected: %d", code, tt.expectedCode)
+			}
+		})
+	}
+}
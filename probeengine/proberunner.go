@@ -2,22 +2,29 @@ package probeengine
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
 
 	sdk "github.com/citihub/probr-sdk"
+	"github.com/citihub/probr-sdk/audit"
+	"github.com/citihub/probr-sdk/events"
 	"github.com/cucumber/godog"
 )
 
 // ProbeRunner describes the interface that should be implemented to support the execution of tests.
 type ProbeRunner interface {
-	RunProbe(t *GodogProbe) error
+	RunProbe(ctx context.Context, t *GodogProbe) error
 }
 
 // ProbeHandlerFunc describes a callback that should be implemented by test cases in order for ProbeRunner
 // to be able to execute the test case.
-type ProbeHandlerFunc func(t *GodogProbe) (int, *bytes.Buffer, error)
+type ProbeHandlerFunc func(ctx context.Context, t *GodogProbe) (int, *bytes.Buffer, error)
+
+// This is a var-func in order to be able to mock the godog test run during testing.
+var probeHandlerFunc ProbeHandlerFunc = GodogProbeHandler
 
 // GodogProbe encapsulates the specific data that GoDog feature based tests require in order to run.   This
 // structure will be passed to the test handler callback.
@@ -30,19 +37,38 @@ type GodogProbe struct {
 	Status              *ProbeStatus
 	Results             *bytes.Buffer
 	Tags                string
+	OutputType          string
+	Dependencies        []string
+	Attempt             int                 // Set when failed scenarios are retried; see RetryPolicy
+	summary             *audit.SummaryState // Used to record results in the audit; see auditScenario
 }
 
-// RunProbe runs the test cases described by the supplied Probe
-func (ps *ProbeStore) RunProbe(probe *GodogProbe) (int, error) {
+// RunProbe runs the test cases described by the supplied Probe.
+// If ctx is cancelled or its deadline passes first, the probe is marked as Error and the reason is audited.
+// Failed scenarios are retried according to their RetryPolicy, and every attempt is audited.
+func (ps *ProbeStore) RunProbe(ctx context.Context, probe *GodogProbe) (int, error) {
 
 	if probe == nil {
-		ps.Summary.GetProbeLog(probe.Name).Result = "Internal Error - Probe not found"
 		return 2, fmt.Errorf("probe is nil - cannot run test")
 	}
 
-	s, o, err := GodogProbeHandler(probe)
+	ps.Lock.Lock()
+	*probe.Status = Running
+	ps.Summary.LogProbeStart(probe.Name)
+	ps.Lock.Unlock()
+	ps.publishProbeEvent(events.ProbeStarted, probe.Name)
+
+	s, o, err := probeHandlerFunc(ctx, probe)
+	if err == nil {
+		s, o, err = ps.retryFailedScenarios(ctx, probe, s, o)
+	}
 
-	if s == 0 {
+	ps.Lock.Lock()
+	defer ps.Lock.Unlock()
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		*probe.Status = Error
+		ps.Summary.LogProbeError(probe.Name, err)
+	} else if s == 0 {
 		// success
 		*probe.Status = CompleteSuccess
 	} else {
@@ -58,7 +84,7 @@ func (ps *ProbeStore) RunProbe(probe *GodogProbe) (int, error) {
 func GetAllProbeResults(ps *ProbeStore) (allResults map[string]string, success bool) {
 	allResults = make(map[string]string)
 	success = true
-	for name := range ps.Probes {
+	for _, name := range ps.probeNames() {
 		probeResults, name, err := readProbeResults(ps, name)
 		if err != nil {
 			allResults[name] = err.Error()
@@ -70,13 +96,26 @@ func GetAllProbeResults(ps *ProbeStore) (allResults map[string]string, success b
 	return
 }
 
+// GetProbeResults returns the results held in memory for the probe identified by the given name.
+// Results are only available once the probe has run with an OutputType of INMEM or BOTH.
+func (ps *ProbeStore) GetProbeResults(name string) (string, error) {
+	probeResults, _, err := readProbeResults(ps, name)
+	return probeResults, err
+}
+
 func readProbeResults(ps *ProbeStore, name string) (probeResults, probeName string, err error) {
 	p, err := ps.GetProbe(name)
 	if err != nil {
 		return
 	}
-	probeResults = p.Results.String()
+	ps.Lock.RLock()
+	defer ps.Lock.RUnlock()
 	probeName = p.Name
+	if p.Results == nil {
+		err = fmt.Errorf("no results in memory for probe '%s'; OutputType must be %s or %s", name, OutputTypeInMem, OutputTypeBoth)
+		return
+	}
+	probeResults = p.Results.String()
 	return
 }
 
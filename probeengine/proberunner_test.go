@@ -0,0 +1,122 @@
+package probeengine
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/citihub/probr-sdk/audit"
+	"github.com/citihub/probr-sdk/config"
+	"github.com/citihub/probr-sdk/utils"
+	"github.com/cucumber/messages-go/v10"
+)
+
+func TestGetProbeResults(t *testing.T) {
+	config.Vars.WriteDirectory = filepath.Join(testFolder, utils.RandomString(10))
+	defer func() {
+		os.RemoveAll(config.Vars.WriteDirectory) // Delete test data after tests
+		config.Vars.WriteDirectory = ""
+		probeHandlerFunc = GodogProbeHandler // Restoring to original function after test
+	}()
+
+	// Only hold results in memory for in-mem output types, as GodogProbeHandler does
+	probeHandlerFunc = func(ctx context.Context, probe *GodogProbe) (int, *bytes.Buffer, error) {
+		if probe.OutputType == OutputTypeIO {
+			return 0, nil, nil
+		}
+		return 0, bytes.NewBufferString("results for " + probe.Name), nil
+	}
+
+	tests := []struct {
+		testName        string
+		outputType      string
+		expectedResults string
+		expectedErr     bool
+	}{
+		{
+			testName:    "GetProbeResults_WithFileOutput_ShouldReturnError",
+			outputType:  OutputTypeIO,
+			expectedErr: true,
+		},
+		{
+			testName:        "GetProbeResults_WithInMemOutput_ShouldReturnResults",
+			outputType:      OutputTypeInMem,
+			expectedResults: "results for " + probeName,
+		},
+		{
+			testName:        "GetProbeResults_WithBothOutput_ShouldReturnResults",
+			outputType:      OutputTypeBoth,
+			expectedResults: "results for " + probeName,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			summary := audit.NewSummaryState(probeStoreName)
+			ps := NewProbeStore(probeStoreName, "", &summary)
+			ps.OutputType = tt.outputType
+			ps.AddProbe(TestProbe{name: probeName})
+			ps.ExecAllProbes(context.Background())
+
+			got, err := ps.GetProbeResults(probeName)
+			if (err != nil) != tt.expectedErr {
+				t.Errorf("GetProbeResults() error = %v, expected error: %v", err, tt.expectedErr)
+			}
+			if got != tt.expectedResults {
+				t.Errorf("GetProbeResults() = %v, Expected: %v", got, tt.expectedResults)
+			}
+
+			allResults, success := GetAllProbeResults(ps)
+			if success == tt.expectedErr {
+				t.Errorf("GetAllProbeResults() success = %v, Expected: %v", success, !tt.expectedErr)
+			}
+			if _, found := allResults[probeName]; !found {
+				t.Errorf("GetAllProbeResults() did not contain an entry for '%s'", probeName)
+			}
+		})
+	}
+}
+
+func TestRunProbe_Timing(t *testing.T) {
+	config.Vars.WriteDirectory = filepath.Join(testFolder, utils.RandomString(10))
+	defer func() {
+		os.RemoveAll(config.Vars.WriteDirectory) // Delete test data after tests
+		config.Vars.WriteDirectory = ""
+		probeHandlerFunc = GodogProbeHandler // Restoring to original function after test
+	}()
+
+	summary := audit.NewSummaryState(probeStoreName)
+	ps := NewProbeStore(probeStoreName, "", &summary)
+	ps.AddProbe(TestProbe{name: probeName})
+	probeHandlerFunc = func(ctx context.Context, probe *GodogProbe) (int, *bytes.Buffer, error) {
+		scenario := ps.Summary.GetProbeLog(probe.Name).InitializeAuditor("scenario", []*messages.Pickle_PickleTag{})
+		time.Sleep(5 * time.Millisecond)
+		scenario.AuditScenarioStep("first step", "", nil, nil)
+		time.Sleep(5 * time.Millisecond)
+		scenario.AuditScenarioStep("second step", "", nil, nil)
+		return 0, nil, nil
+	}
+	before := time.Now()
+	ps.ExecAllProbes(context.Background())
+
+	probe := summary.Probes[probeName]
+	scenario := probe.Scenarios[0]
+	first, second := scenario.Steps[0], scenario.Steps[1]
+	if first.Duration < 5*time.Millisecond || second.Duration < 5*time.Millisecond {
+		t.Errorf("Expected each step to take at least 5ms, but found %v and %v", first.Duration, second.Duration)
+	}
+	if !second.StartTime.Equal(first.EndTime) {
+		t.Errorf("Expected the second step to start when the first ended, but found %v and %v", second.StartTime, first.EndTime)
+	}
+	if !scenario.StartTime.Equal(first.StartTime) || !scenario.EndTime.Equal(second.EndTime) {
+		t.Errorf("Expected the scenario to span its steps, but found %v to %v", scenario.StartTime, scenario.EndTime)
+	}
+	if scenario.StartTime.Before(probe.StartTime) || probe.EndTime.Before(scenario.EndTime) || probe.Duration < scenario.Duration {
+		t.Errorf("Expected the probe to span its scenarios, but found %v to %v", probe.StartTime, probe.EndTime)
+	}
+	if probe.StartTime.Before(before) || !summary.StartTime.Equal(probe.StartTime) || !summary.EndTime.Equal(probe.EndTime) {
+		t.Errorf("Expected the summary to span its probes, but found %v to %v", summary.StartTime, summary.EndTime)
+	}
+}
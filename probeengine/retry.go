@@ -0,0 +1,194 @@
+package probeengine
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/citihub/probr-sdk/audit"
+	"github.com/citihub/probr-sdk/config"
+)
+
+// RetryPolicy describes how failed scenarios are retried. MaxAttempts includes the first attempt.
+// The wait before a retry starts at Backoff and doubles with every further attempt.
+// A failed scenario is only retried if one of its step errors matches RetryableErrors; if none are set, any failure is retried.
+type RetryPolicy struct {
+	MaxAttempts     int
+	Backoff         time.Duration
+	RetryableErrors []*regexp.Regexp
+}
+
+// NewRetryPolicy creates a RetryPolicy from the provided config options.
+// Invalid expressions in RetryableErrors are logged and ignored.
+func NewRetryPolicy(r config.Retry) RetryPolicy {
+	policy := RetryPolicy{
+		MaxAttempts: r.GetMaxAttempts(),
+		Backoff:     r.GetBackoff(),
+	}
+	for _, expr := range r.RetryableErrors {
+		if expr == "" {
+			continue
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			log.Printf("[ERROR] Could not parse retryable error '%s': %v", expr, err)
+			continue
+		}
+		policy.RetryableErrors = append(policy.RetryableErrors, re)
+	}
+	return policy
+}
+
+// retryable reports whether a scenario that failed with the given step errors may be retried
+func (rp RetryPolicy) retryable(errs []string) bool {
+	if len(rp.RetryableErrors) == 0 {
+		return true
+	}
+	for _, err := range errs {
+		for _, re := range rp.RetryableErrors {
+			if re.MatchString(err) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// wait returns the backoff to apply before the specified attempt, where attempt 2 is the first retry
+func (rp RetryPolicy) wait(attempt int) time.Duration {
+	if attempt < 2 {
+		return 0
+	}
+	return rp.Backoff << uint(attempt-2)
+}
+
+// retryPolicy returns the policy for a scenario in the named probe, as configured for this ProbeStore's pack
+func (ps *ProbeStore) retryPolicy(probeName string, scenarioTags []string) RetryPolicy {
+	return NewRetryPolicy(config.Vars.GetRetry(ps.Name, probeName, scenarioTags))
+}
+
+// scenarioRetry describes the failed scenarios of a probe that are to be retried together
+type scenarioRetry struct {
+	names   []string      // Scenario names, as recorded in the audit
+	tags    []string      // A tag unique to each scenario, used to run only the retried scenarios
+	attempt int           // The highest attempt number among the scenarios
+	wait    time.Duration // The longest backoff among the scenarios
+	all     bool          // True if every failed scenario is retried
+}
+
+// failedScenarios selects the failed scenarios of the probe that may be retried according to their RetryPolicy.
+// Scenarios without a tag that is unique within the probe can't be run on their own, so are not retried.
+func (ps *ProbeStore) failedScenarios(probe *GodogProbe) (retry scenarioRetry) {
+	ps.Lock.RLock()
+	defer ps.Lock.RUnlock()
+
+	var scenarios []*audit.Scenario
+	if p := ps.Summary.ProbeSnapshot(probe.Name); p != nil {
+		scenarios = p.Scenarios
+	}
+	tagCount := make(map[string]int)
+	for _, s := range scenarios {
+		for _, tag := range s.Tags {
+			tagCount[tag]++
+		}
+	}
+
+	retry.all = true
+	selected := make(map[string]bool)
+	for _, s := range scenarios {
+		if s.Result != "Failed" || selected[s.Name] {
+			continue
+		}
+		policy := ps.retryPolicy(probe.Name, s.Tags)
+		attempt := len(s.PreviousAttempts) + 2
+		if attempt > policy.MaxAttempts || !policy.retryable(s.FailedStepErrors()) {
+			retry.all = false
+			continue
+		}
+		tag := uniqueTag(s.Tags, tagCount)
+		if tag == "" {
+			log.Printf("[WARN] Scenario '%s' in probe '%s' has no unique tag, so can't be retried", s.Name, probe.Name)
+			retry.all = false
+			continue
+		}
+		selected[s.Name] = true
+		retry.names = append(retry.names, s.Name)
+		retry.tags = append(retry.tags, tag)
+		if attempt > retry.attempt {
+			retry.attempt = attempt
+		}
+		if w := policy.wait(attempt); w > retry.wait {
+			retry.wait = w
+		}
+	}
+	return
+}
+
+func uniqueTag(tags []string, tagCount map[string]int) string {
+	for _, tag := range tags {
+		if tagCount[tag] == 1 {
+			return tag
+		}
+	}
+	return ""
+}
+
+// retryFailedScenarios re-runs the failed scenarios of the probe for as long as their RetryPolicy allows,
+// returning the status, results and error to be used in place of those of the first run.
+// The status of a retry run only replaces the earlier status if every failed scenario was retried in it.
+func (ps *ProbeStore) retryFailedScenarios(ctx context.Context, probe *GodogProbe, s int, o *bytes.Buffer) (int, *bytes.Buffer, error) {
+	for s != 0 {
+		retry := ps.failedScenarios(probe)
+		if len(retry.names) == 0 {
+			break
+		}
+		log.Printf("[NOTICE] Retrying scenarios %q of probe '%s' (attempt %d) in %v", retry.names, probe.Name, retry.attempt, retry.wait)
+		select {
+		case <-time.After(retry.wait):
+		case <-ctx.Done():
+			return s, o, fmt.Errorf("probe '%s' was not retried: %w", probe.Name, ctx.Err())
+		}
+
+		ps.Lock.Lock()
+		for _, name := range retry.names {
+			ps.Summary.GetProbeLog(probe.Name).RetryScenario(name)
+		}
+		ps.Lock.Unlock()
+
+		retryProbe := *probe
+		retryProbe.Attempt = retry.attempt
+		retryProbe.Tags = retryTags(probe.Tags, retry.tags)
+		rs, ro, err := probeHandlerFunc(ctx, &retryProbe)
+		o = appendResults(o, ro)
+		if err != nil {
+			return rs, o, err
+		}
+		if retry.all {
+			s = rs
+		}
+	}
+	return s, o, nil
+}
+
+// retryTags restricts the probe's tag filter to the scenarios identified by the provided tags
+func retryTags(tags string, scenarioTags []string) string {
+	filter := strings.Join(scenarioTags, ",")
+	if tags == "" {
+		return filter
+	}
+	return fmt.Sprintf("%s && %s", tags, filter)
+}
+
+func appendResults(o, retry *bytes.Buffer) *bytes.Buffer {
+	if o == nil {
+		return retry
+	}
+	if retry != nil {
+		o.Write(retry.Bytes())
+	}
+	return o
+}
@@ -0,0 +1,140 @@
+package probeengine
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/citihub/probr-sdk/audit"
+	"github.com/citihub/probr-sdk/config"
+	"github.com/citihub/probr-sdk/utils"
+	"github.com/cucumber/messages-go/v10"
+)
+
+func TestRunProbe_Retry(t *testing.T) {
+	config.Vars.WriteDirectory = filepath.Join(testFolder, utils.RandomString(10))
+	defer func() {
+		os.RemoveAll(config.Vars.WriteDirectory) // Delete test data after tests
+		config.Vars.WriteDirectory = ""
+		config.Vars.Retry = config.Retry{}
+		probeHandlerFunc = GodogProbeHandler // Restoring to original function after test
+	}()
+
+	tests := []struct {
+		testName            string
+		retry               config.Retry
+		flakyFailures       int // Number of attempts of the flaky scenario that fail
+		expectedStatus      int
+		expectedRuns        int
+		expectedResult      string
+		expectedProbeResult string
+	}{
+		{
+			testName:            "RunProbe_WithFlakyScenario_ShouldPassAfterRetry",
+			retry:               config.Retry{MaxAttempts: "3", Backoff: "1ms"},
+			flakyFailures:       1,
+			expectedStatus:      0,
+			expectedRuns:        2,
+			expectedResult:      "Passed After Retry",
+			expectedProbeResult: "Success After Retry",
+		},
+		{
+			testName:            "RunProbe_WithRetryDisabled_ShouldFail",
+			retry:               config.Retry{MaxAttempts: "1"},
+			flakyFailures:       1,
+			expectedStatus:      1,
+			expectedRuns:        1,
+			expectedResult:      "Failed",
+			expectedProbeResult: "Failed",
+		},
+		{
+			testName:            "RunProbe_WithNonRetryableError_ShouldFail",
+			retry:               config.Retry{MaxAttempts: "3", RetryableErrors: []string{"throttl"}},
+			flakyFailures:       1,
+			expectedStatus:      1,
+			expectedRuns:        1,
+			expectedResult:      "Failed",
+			expectedProbeResult: "Failed",
+		},
+		{
+			testName:            "RunProbe_WithRetryableError_ShouldPassAfterRetry",
+			retry:               config.Retry{MaxAttempts: "3", RetryableErrors: []string{"ImagePull"}},
+			flakyFailures:       1,
+			expectedStatus:      0,
+			expectedRuns:        2,
+			expectedResult:      "Passed After Retry",
+			expectedProbeResult: "Success After Retry",
+		},
+		{
+			testName:            "RunProbe_WithPersistentFailure_ShouldFailAfterMaxAttempts",
+			retry:               config.Retry{MaxAttempts: "3"},
+			flakyFailures:       5,
+			expectedStatus:      1,
+			expectedRuns:        3,
+			expectedResult:      "Failed",
+			expectedProbeResult: "Failed",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			config.Vars.Retry = tt.retry
+			summary := audit.NewSummaryState(probeStoreName)
+			ps := NewProbeStore(probeStoreName, "", &summary)
+			ps.AddProbe(TestProbe{name: probeName})
+
+			var runs []*GodogProbe
+			probeHandlerFunc = func(ctx context.Context, probe *GodogProbe) (int, *bytes.Buffer, error) {
+				runs = append(runs, probe)
+				status := 0
+				probeLog := ps.Summary.GetProbeLog(probe.Name)
+				if !strings.Contains(probe.Tags, "@flaky") || strings.Contains(probe.Tags, "@stable") {
+					probeLog.InitializeAuditor("stable", []*messages.Pickle_PickleTag{{Name: "@stable"}}).AuditScenarioStep("a step", "", nil, nil)
+				}
+				var err error
+				if len(runs) <= tt.flakyFailures {
+					err = errors.New("container is waiting: ErrImagePull")
+					status = 1
+				}
+				flaky := probeLog.InitializeAuditor("flaky", []*messages.Pickle_PickleTag{{Name: "@flaky"}})
+				flaky.AuditScenarioStep("a given", "", nil, nil) // A failed first step is audited as "Given Not Met", which is not retried
+				flaky.AuditScenarioStep("a step", "", nil, err)
+				return status, nil, nil
+			}
+
+			status, _ := ps.ExecAllProbes(context.Background())
+			if status != tt.expectedStatus {
+				t.Errorf("ExecAllProbes() = %v, Expected: %v", status, tt.expectedStatus)
+			}
+			if len(runs) != tt.expectedRuns {
+				t.Fatalf("Expected probe to be run %d times, but found %d", tt.expectedRuns, len(runs))
+			}
+			for i, run := range runs[1:] {
+				if run.Tags != "@flaky" || run.Attempt != i+2 {
+					t.Errorf("Expected retry %d to run only the flaky scenario as attempt %d, but found tags '%s' and attempt %d", i+1, i+2, run.Tags, run.Attempt)
+				}
+			}
+
+			probe := summary.Probes[probeName]
+			if len(probe.Scenarios) != 2 {
+				t.Fatalf("Expected 2 audited scenarios, but found %d", len(probe.Scenarios))
+			}
+			flaky := probe.Scenarios[1]
+			if flaky.Result != tt.expectedResult {
+				t.Errorf("Expected flaky scenario result '%s', but found '%s'", tt.expectedResult, flaky.Result)
+			}
+			if len(flaky.PreviousAttempts) != tt.expectedRuns-1 {
+				t.Errorf("Expected %d previous attempts to be audited, but found %d", tt.expectedRuns-1, len(flaky.PreviousAttempts))
+			}
+			if probe.Scenarios[0].Result != "Passed" || len(probe.Scenarios[0].PreviousAttempts) != 0 {
+				t.Errorf("Expected stable scenario to pass without retry, but found %+v", probe.Scenarios[0])
+			}
+			if probe.Result != tt.expectedProbeResult {
+				t.Errorf("Expected probe result '%s', but found '%s'", tt.expectedProbeResult, probe.Result)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package probeengine
+
+import (
+	"time"
+
+	"github.com/cucumber/godog"
+
+	"github.com/citihub/probr-sdk/audit"
+)
+
+// scenarioAuditor records the result of each step of a scenario in the audit of the probe being run, as godog reports
+// them to the scenario's hooks, so that the results files and the audit are produced by the same run.
+// Steps may still provide a description and payload via audit.Scenario.AuditScenarioStep.
+type scenarioAuditor struct {
+	run       *suiteRun
+	scenario  *audit.Scenario // The scenario being run, or nil if it can't be audited
+	stepStart time.Time
+	audited   int // Steps reported to afterStep; godog runs the steps in order, so these are the first steps of the scenario
+}
+
+// auditScenario registers hooks that audit the scenario initialized with ctx. They must be registered after the probe's
+// own hooks, so that an entry created for the scenario by the probe's BeforeScenario hook is reused; see audit.Probe.PickleAuditor.
+func (run *suiteRun) auditScenario(ctx *godog.ScenarioContext) {
+	a := &scenarioAuditor{run: run}
+	ctx.BeforeScenario(a.beforeScenario)
+	ctx.BeforeStep(a.beforeStep)
+	ctx.AfterStep(a.afterStep)
+	ctx.AfterScenario(a.afterScenario)
+}
+
+func (a *scenarioAuditor) beforeScenario(s *godog.Scenario) {
+	a.scenario, a.audited = nil, 0
+	a.run.do(func() {
+		if gd := a.run.probe; gd.summary != nil {
+			a.scenario = gd.summary.GetProbeLog(gd.Name).PickleAuditor(s)
+		}
+	})
+}
+
+func (a *scenarioAuditor) beforeStep(*godog.Step) {
+	a.stepStart = time.Now()
+}
+
+// afterStep audits a step that was run; godog doesn't call it for steps that are undefined or skipped
+func (a *scenarioAuditor) afterStep(step *godog.Step, err error) {
+	result := "Passed"
+	if err == godog.ErrPending {
+		result = "Pending"
+	} else if err != nil {
+		result = "Failed"
+	}
+	a.audited++
+	a.auditStep(step, result, time.Since(a.stepStart), err)
+}
+
+// afterScenario audits the steps that were not run. The first is undefined if the scenario ended because a step was
+// undefined; godog skips every step after it.
+func (a *scenarioAuditor) afterScenario(s *godog.Scenario, err error) {
+	if a.audited > len(s.Steps) {
+		return
+	}
+	for i, step := range s.Steps[a.audited:] {
+		result := "Skipped"
+		if i == 0 && err == godog.ErrUndefined {
+			result = "Undefined"
+		}
+		a.auditStep(step, result, 0, nil)
+	}
+}
+
+func (a *scenarioAuditor) auditStep(step *godog.Step, result string, duration time.Duration, err error) {
+	if a.scenario == nil {
+		return
+	}
+	a.run.do(func() {
+		a.scenario.AuditStepResult(step.Text, "", result, duration, err)
+	})
+}
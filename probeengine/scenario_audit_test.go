@@ -0,0 +1,129 @@
+package probeengine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/citihub/probr-sdk/audit"
+	"github.com/citihub/probr-sdk/config"
+	"github.com/citihub/probr-sdk/utils"
+	"github.com/cucumber/godog"
+)
+
+const auditTestFeature = `Feature: audited probe
+
+  @probes/test
+  Scenario: a failing scenario
+    Given a cluster is available
+    When a pod is created
+    Then the pod is removed
+
+  Scenario: a scenario with an undefined step
+    Given a cluster is available
+    When a step is undefined
+    Then the pod is removed
+`
+
+// auditTestProbe creates an audit entry for each scenario and describes its first step, as service packs do
+type auditTestProbe struct {
+	TestProbe
+	summary  *audit.SummaryState
+	scenario *audit.Scenario
+}
+
+func (probe *auditTestProbe) ScenarioInitialize(ctx *godog.ScenarioContext) {
+	ctx.BeforeScenario(func(s *godog.Scenario) {
+		probe.scenario = probe.summary.GetProbeLog(probe.name).InitializeAuditor(s.Name, s.Tags)
+	})
+	ctx.Step(`^a cluster is available$`, probe.aClusterIsAvailable)
+	ctx.Step(`^a pod is created$`, func() error { return errors.New("pod was not created") })
+	ctx.Step(`^the pod is removed$`, func() error { return nil })
+}
+
+func (probe *auditTestProbe) aClusterIsAvailable() error {
+	var err error
+	defer func() {
+		probe.scenario.AuditScenarioStep("ignored", "Checks the cluster", "payload", err)
+	}()
+	return err
+}
+
+func TestRunTestSuite_ShouldAuditScenariosAndWriteEachFormat(t *testing.T) {
+	config.Vars.WriteDirectory = filepath.Join(testFolder, utils.RandomString(10))
+	defer func() {
+		os.RemoveAll(config.Vars.WriteDirectory) // Delete test data after tests
+		config.Vars.WriteDirectory = ""
+	}()
+	featurePath := filepath.Join(config.Vars.WriteDirectory, "audited.feature")
+	_ = os.MkdirAll(config.Vars.WriteDirectory, 0755)
+	if err := ioutil.WriteFile(featurePath, []byte(auditTestFeature), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	summary := audit.NewSummaryState(probeStoreName)
+	probe := &auditTestProbe{TestProbe: TestProbe{name: probeName}, summary: &summary}
+	ps := NewProbeStore(probeStoreName, "", &summary)
+	ps.AddProbe(probe)
+	gd := ps.Probes[probeName]
+	gd.FeaturePath = featurePath
+
+	var cucumber, junit bytes.Buffer
+	status, err := runTestSuite(context.Background(), resultsOutputs{{format: "cucumber", w: &cucumber}, {format: "junit", w: &junit}}, gd)
+	if err != nil || status != 1 {
+		t.Errorf("runTestSuite() = %d, %v, Expected: 1 for a failed scenario", status, err)
+	}
+
+	var features []struct {
+		Elements []struct{ Name string }
+	}
+	if err := json.Unmarshal(cucumber.Bytes(), &features); err != nil || len(features) != 1 || len(features[0].Elements) != 2 {
+		t.Errorf("Expected cucumber results for both scenarios, but found %s (%v)", cucumber.String(), err)
+	}
+	if !strings.Contains(junit.String(), "<testsuites") || !strings.Contains(junit.String(), "a failing scenario") {
+		t.Errorf("Expected junit results, but found %s", junit.String())
+	}
+
+	scenarios := summary.GetProbeLog(probeName).Scenarios
+	if len(scenarios) != 2 {
+		t.Fatalf("Expected each scenario to be audited once, as later formats are not audited, but found %d scenarios", len(scenarios))
+	}
+	tests := []struct {
+		testName string
+		scenario *audit.Scenario
+		result   string
+		steps    []string
+	}{
+		{"RunTestSuite_WithFailedStep_ShouldAuditFailedAndSkippedSteps", scenarios[0], "Failed", []string{"Passed", "Failed", "Skipped"}},
+		{"RunTestSuite_WithUndefinedStep_ShouldAuditUndefinedAndSkippedSteps", scenarios[1], "Passed", []string{"Passed", "Undefined", "Skipped"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			if tt.scenario.Result != tt.result || len(tt.scenario.Steps) != len(tt.steps) {
+				t.Fatalf("Expected result '%s' with %d steps, but found '%s' with %d steps", tt.result, len(tt.steps), tt.scenario.Result, len(tt.scenario.Steps))
+			}
+			for i, result := range tt.steps {
+				if tt.scenario.Steps[i].Result != result {
+					t.Errorf("Expected step %d to be %s, but found %+v", i+1, result, tt.scenario.Steps[i])
+				}
+			}
+		})
+	}
+
+	first := scenarios[0].Steps[0]
+	if first.Name != "a cluster is available" || first.Function != "aClusterIsAvailable" || first.Description != "Checks the cluster" || first.Payload != "payload" {
+		t.Errorf("Expected the step name from godog, with the function, description and payload provided by the step, but found %+v", first)
+	}
+	if failed := scenarios[0].Steps[1]; failed.Error != "pod was not created" {
+		t.Errorf("Expected the error of the failed step to be audited, but found '%s'", failed.Error)
+	}
+	if len(scenarios[0].Tags) != 1 || scenarios[0].Tags[0] != "@probes/test" {
+		t.Errorf("Expected the entry created by the probe's hook to be reused, but found tags %v", scenarios[0].Tags)
+	}
+}
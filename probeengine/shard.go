@@ -0,0 +1,149 @@
+package probeengine
+
+import (
+	"fmt"
+	"hash/fnv"
+	"log"
+	"sort"
+	"time"
+)
+
+// shardGroup is a set of probes that must be run by the same shard, because they depend on each other
+type shardGroup struct {
+	key      string // The first probe name in the group, by which it is identified
+	names    []string
+	duration time.Duration
+}
+
+// shardAssignments returns the shard that each probe in the store is assigned to. Probes that depend on each other
+// are assigned to the same shard, so that dependencies are honoured. Groups are assigned by a stable hash of their
+// first probe name, unless ShardDurations are known, in which case the longest groups are assigned first to whichever
+// shard has the shortest expected duration. Every shard must use the same ShardDurations to agree on the assignments.
+func (ps *ProbeStore) shardAssignments() map[string]int {
+	groups := ps.shardGroups()
+	assignments := make(map[string]int)
+	if !ps.weighShardGroups(groups) {
+		for _, g := range groups {
+			h := fnv.New32a()
+			h.Write([]byte(g.key))
+			for _, name := range g.names {
+				assignments[name] = int(h.Sum32() % uint32(ps.ShardTotal))
+			}
+		}
+		return assignments
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].duration != groups[j].duration {
+			return groups[i].duration > groups[j].duration
+		}
+		return groups[i].key < groups[j].key
+	})
+	load := make([]time.Duration, ps.ShardTotal)
+	for _, g := range groups {
+		shard := 0
+		for i := range load {
+			if load[i] < load[shard] {
+				shard = i
+			}
+		}
+		load[shard] += g.duration
+		for _, name := range g.names {
+			assignments[name] = shard
+		}
+	}
+	return assignments
+}
+
+// shardGroups groups the probes in the store that are connected by dependencies, ordered by key
+func (ps *ProbeStore) shardGroups() []*shardGroup {
+	ps.Lock.RLock()
+	defer ps.Lock.RUnlock()
+
+	parent := make(map[string]string)
+	var find func(string) string
+	find = func(name string) string {
+		if parent[name] != name {
+			parent[name] = find(parent[name])
+		}
+		return parent[name]
+	}
+	for name := range ps.Probes {
+		parent[name] = name
+	}
+	for name, probe := range ps.Probes {
+		for _, dependency := range probe.Dependencies {
+			if _, exists := ps.Probes[dependency]; exists {
+				parent[find(name)] = find(dependency)
+			}
+		}
+	}
+
+	byRoot := make(map[string]*shardGroup)
+	for name := range ps.Probes {
+		root := find(name)
+		if byRoot[root] == nil {
+			byRoot[root] = &shardGroup{}
+		}
+		byRoot[root].names = append(byRoot[root].names, name)
+	}
+	var groups []*shardGroup
+	for _, g := range byRoot {
+		sort.Strings(g.names)
+		g.key = g.names[0]
+		groups = append(groups, g)
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].key < groups[j].key })
+	return groups
+}
+
+// weighShardGroups sets the expected duration of each group from ShardDurations. Probes without a known duration
+// are expected to take the average of those that are known. Returns false if no durations are known.
+func (ps *ProbeStore) weighShardGroups(groups []*shardGroup) bool {
+	var total time.Duration
+	var known int
+	for _, g := range groups {
+		for _, name := range g.names {
+			if d, found := ps.ShardDurations[name]; found {
+				total += d
+				known++
+			}
+		}
+	}
+	if known == 0 {
+		return false
+	}
+	average := total / time.Duration(known)
+	for _, g := range groups {
+		for _, name := range g.names {
+			d, found := ps.ShardDurations[name]
+			if !found {
+				d = average
+			}
+			g.duration += d
+		}
+	}
+	return true
+}
+
+// applyShard removes probes assigned to other shards from the store and its summary, so that only this shard's
+// probes are run and reported. Use audit.MergeSummaries to combine the summaries of every shard.
+// It has no effect unless ShardTotal is greater than one, and is only applied once.
+func (ps *ProbeStore) applyShard() {
+	if ps.ShardTotal < 2 || ps.sharded {
+		return
+	}
+	assignments := ps.shardAssignments()
+
+	ps.Lock.Lock()
+	defer ps.Lock.Unlock()
+	ps.sharded = true
+	for name, shard := range assignments {
+		if shard != ps.ShardIndex {
+			delete(ps.Probes, name)
+			ps.Summary.RemoveProbe(name)
+		}
+	}
+	ps.Summary.LogMeta("shard", fmt.Sprintf("%d/%d", ps.ShardIndex, ps.ShardTotal))
+	log.Printf("[NOTICE] Running %d of %d probes as shard %d of %d", len(ps.Probes), len(assignments), ps.ShardIndex, ps.ShardTotal)
+}
@@ -0,0 +1,124 @@
+package probeengine
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	sdk "github.com/citihub/probr-sdk"
+	"github.com/citihub/probr-sdk/audit"
+	"github.com/citihub/probr-sdk/config"
+	"github.com/citihub/probr-sdk/utils"
+	"github.com/cucumber/messages-go/v10"
+)
+
+func TestShardAssignments(t *testing.T) {
+	newStore := func(total int, durations map[string]time.Duration, reverse bool) *ProbeStore {
+		summary := audit.NewSummaryState(probeStoreName)
+		ps := NewProbeStore(probeStoreName, "", &summary)
+		ps.ShardTotal = total
+		ps.ShardDurations = durations
+		var probes []Probe
+		for i := 0; i < 10; i++ {
+			probes = append(probes, TestProbe{name: fmt.Sprintf("%s_%d", probeName, i)})
+		}
+		probes = append(probes, dependentTestProbe{TestProbe{name: "dependent_probe"}, []string{probeName + "_3"}})
+		for i := range probes {
+			if reverse {
+				ps.AddProbe(probes[len(probes)-1-i])
+			} else {
+				ps.AddProbe(probes[i])
+			}
+		}
+		return ps
+	}
+
+	t.Run("shardAssignments_WithoutDurations_ShouldBeStable", func(t *testing.T) {
+		assignments := newStore(3, nil, false).shardAssignments()
+		reversed := newStore(3, nil, true).shardAssignments()
+		if len(assignments) != 11 {
+			t.Errorf("Expected all 11 probes to be assigned a shard, but found %d", len(assignments))
+		}
+		for name, shard := range assignments {
+			if shard < 0 || shard > 2 {
+				t.Errorf("Expected probe '%s' to be assigned a shard between 0 and 2, but found %d", name, shard)
+			}
+			if reversed[name] != shard {
+				t.Errorf("Expected probe '%s' to be assigned shard %d regardless of order, but found %d", name, shard, reversed[name])
+			}
+		}
+		if assignments["dependent_probe"] != assignments[probeName+"_3"] {
+			t.Errorf("Expected dependent_probe to be assigned the same shard as its dependency")
+		}
+	})
+
+	t.Run("shardAssignments_WithDurations_ShouldBalanceShards", func(t *testing.T) {
+		durations := map[string]time.Duration{probeName + "_0": time.Hour}
+		for i := 1; i < 10; i++ {
+			durations[fmt.Sprintf("%s_%d", probeName, i)] = time.Minute
+		}
+		assignments := newStore(2, durations, false).shardAssignments()
+		longShard := assignments[probeName+"_0"]
+		for name, shard := range assignments {
+			if name != probeName+"_0" && shard == longShard {
+				t.Errorf("Expected the longest probe to have a shard to itself, but '%s' was also assigned shard %d", name, shard)
+			}
+		}
+	})
+}
+
+func TestExecAllProbes_Shards(t *testing.T) {
+	baseDirectory := filepath.Join(testFolder, utils.RandomString(10))
+	installDir := sdk.GlobalConfig.InstallDir
+	sdk.GlobalConfig.InstallDir = filepath.Join(baseDirectory, "install") // The run directory is written by WriteSummary
+	defer func() {
+		os.RemoveAll(baseDirectory) // Delete test data after tests
+		config.Vars.WriteDirectory = ""
+		sdk.GlobalConfig.InstallDir = installDir
+		probeHandlerFunc = GodogProbeHandler // Restoring to original function after test
+	}()
+
+	var summaryPaths []string
+	executed := make(map[string]int)
+	for shard := 0; shard < 2; shard++ {
+		config.Vars.WriteDirectory = filepath.Join(baseDirectory, fmt.Sprintf("shard_%d", shard))
+		summary := audit.NewSummaryState(probeStoreName)
+		ps := NewProbeStore(probeStoreName, "", &summary)
+		ps.ShardIndex, ps.ShardTotal = shard, 2
+		for i := 0; i < 6; i++ {
+			ps.AddProbe(TestProbe{name: fmt.Sprintf("%s_%d", probeName, i)})
+		}
+		probeHandlerFunc = func(ctx context.Context, probe *GodogProbe) (int, *bytes.Buffer, error) {
+			executed[probe.Name]++
+			ps.Summary.GetProbeLog(probe.Name).InitializeAuditor("scenario", []*messages.Pickle_PickleTag{}).AuditScenarioStep("a step", "", nil, nil)
+			return 0, nil, nil
+		}
+		ps.ExecAllProbes(context.Background())
+		summary.WriteSummary()
+		summaryPaths = append(summaryPaths, filepath.Join(config.Vars.GetWriteDirectory(), "summary.json"))
+	}
+
+	for i := 0; i < 6; i++ {
+		name := fmt.Sprintf("%s_%d", probeName, i)
+		if executed[name] != 1 {
+			t.Errorf("Expected probe '%s' to be run by exactly one shard, but it was run %d times", name, executed[name])
+		}
+	}
+
+	merged, err := audit.MergeSummaries(summaryPaths...)
+	if err != nil {
+		t.Fatalf("MergeSummaries() returned unexpected error: %v", err)
+	}
+	if len(merged.Probes) != 6 || merged.ProbesPassed != 6 {
+		t.Errorf("Expected 6 probes to have passed in the merged summary, but found %d probes with %d passed", len(merged.Probes), merged.ProbesPassed)
+	}
+	for name, probe := range merged.Probes {
+		if len(probe.Scenarios) != 1 {
+			t.Errorf("Expected the audit of probe '%s' to be merged, but found %d scenarios", name, len(probe.Scenarios))
+		}
+	}
+}
@@ -0,0 +1,18 @@
+@k-plan
+@probes/kubernetes/plan_probe
+Feature: Plan which scenarios will be executed
+
+    @k-plan-001
+    @probes/kubernetes/plan_probe/included
+    Scenario: This scenario should always be included
+        Given a Kubernetes cluster exists which we can deploy into
+
+    @k-plan-002
+    @probes/kubernetes/plan_probe/excluded_by_config
+    Scenario: This scenario is excluded by config
+        Given a Kubernetes cluster exists which we can deploy into
+
+    @k-plan-003
+    @probes/kubernetes/plan_probe/excluded_by_tag
+    Scenario: This scenario is excluded by tag
+        Given a Kubernetes cluster exists which we can deploy into
@@ -9,9 +9,7 @@ import (
 	"strings"
 	"testing"
 
-=======
 	"github.com/citihub/probr-sdk/config"
->>>>>>> ca37f2ea8737fdbf02015a4dd8668044b0b6e5d3
 	"github.com/citihub/probr-sdk/utils"
 	apiv1 "k8s.io/api/core/v1"
 )
@@ -48,9 +46,6 @@ func TestPodSpec(t *testing.T) {
 	type args struct {
 		baseName                 string
 		namespace                string
-		image                    string
-=======
->>>>>>> ca37f2ea8737fdbf02015a4dd8668044b0b6e5d3
 		containerSecurityContext *apiv1.SecurityContext
 	}
 	tests := []struct {
@@ -106,17 +101,6 @@ func TestPodSpec(t *testing.T) {
 			want: func(gotPod *apiv1.Pod, args args, t *testing.T) {
 				gotImageName := gotPod.Spec.Containers[0].Image
 				wantImageName := DefaultProbrImageName()
-			name: "Container uses the provided probr image name",
-			args: args{
-				baseName:  "pod4",
-				namespace: "pod4",
-				image:     "thisImage",
-			},
-			want: func(gotPod *apiv1.Pod, args args, t *testing.T) {
-				gotImageName := gotPod.Spec.Containers[0].Image
-				wantImageName := "thisImage"
-=======
->>>>>>> ca37f2ea8737fdbf02015a4dd8668044b0b6e5d3
 				if strings.Compare(gotImageName, wantImageName) != 0 {
 					t.Errorf("PodSpec() got image name '%s', but wanted: '%s'", gotImageName, wantImageName)
 				}
@@ -177,10 +161,7 @@ func TestPodSpec(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := PodSpec(tt.args.baseName, tt.args.namespace, tt.args.image)
-=======
 			got := PodSpec(tt.args.baseName, tt.args.namespace)
->>>>>>> ca37f2ea8737fdbf02015a4dd8668044b0b6e5d3
 			tt.want(got, tt.args, t)
 		})
 	}
@@ -266,8 +247,6 @@ func TestDefaultProbrImageName(t *testing.T) {
 	}
 }
 
-=======
->>>>>>> ca37f2ea8737fdbf02015a4dd8668044b0b6e5d3
 func TestCapabilityObjectList(t *testing.T) {
 	tests := []struct {
 		name         string
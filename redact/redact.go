@@ -0,0 +1,243 @@
+// Package redact masks secrets in values that are written to logs, audit files and config dumps.
+// Built-in rules always apply; further rules may be added with SetRules.
+package redact
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Mask replaces any value that is redacted
+const Mask = "[REDACTED]"
+
+// defaultKeys match the names of keys and fields whose values are secret
+var defaultKeys = []string{
+	`secret`,
+	`passw(or)?d`,
+	`token`,
+	`api_?key`,
+	`private_?key`,
+	`credential`,
+	`authorization`,
+	`client-key-data`,
+	`client-certificate-data`,
+}
+
+// defaultPatterns match secrets wherever they appear in text
+var defaultPatterns = []string{
+	`(?i)\bbearer\s+[a-z0-9\-._~+/]+=*`,
+	`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`,
+}
+
+// Rules describe what is redacted. Keys are matched case-insensitively against the names of map keys, and against
+// names followed by ':' or '=' in text. Patterns are matched against text, and each match is replaced by Mask.
+type Rules struct {
+	keys     []*regexp.Regexp
+	patterns []*regexp.Regexp
+	keyValue *regexp.Regexp // Matches a value assigned to a secret key in text, such as "password": "x" or token=x
+}
+
+var (
+	lock  sync.RWMutex
+	rules = mustRules()
+)
+
+// NewRules compiles the provided key and pattern regular expressions, in addition to the built-in rules
+func NewRules(keys, patterns []string) (*Rules, error) {
+	r := &Rules{}
+	var alternatives []string
+	for _, k := range append(append([]string{}, defaultKeys...), keys...) {
+		re, err := regexp.Compile("(?i)" + k)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction key '%s': %v", k, err)
+		}
+		r.keys = append(r.keys, re)
+		alternatives = append(alternatives, "(?:"+k+")")
+	}
+	for _, p := range append(append([]string{}, defaultPatterns...), patterns...) {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern '%s': %v", p, err)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	r.keyValue = regexp.MustCompile(`(?i)("?[\w-]*(?:` + strings.Join(alternatives, "|") + `)[\w-]*"?\s*[:=]\s*"?)([^"\s,;&}]+)`)
+	return r, nil
+}
+
+func mustRules() *Rules {
+	r, err := NewRules(nil, nil)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// SetRules replaces the rules used by String, Value and writers created by NewWriter
+func SetRules(r *Rules) {
+	lock.Lock()
+	defer lock.Unlock()
+	rules = r
+}
+
+func current() *Rules {
+	lock.RLock()
+	defer lock.RUnlock()
+	return rules
+}
+
+// String redacts secrets in s using the current rules
+func String(s string) string {
+	return current().String(s)
+}
+
+// Value returns a redacted copy of v using the current rules, see Rules.Value
+func Value(v interface{}) interface{} {
+	return current().Value(v)
+}
+
+// String redacts any secrets found in s
+func (r *Rules) String(s string) string {
+	for _, p := range r.patterns {
+		s = p.ReplaceAllString(s, Mask)
+	}
+	return r.keyValue.ReplaceAllString(s, "${1}"+Mask)
+}
+
+// Value returns a copy of v in which the values of secret keys are replaced by Mask, and strings are redacted.
+// The copy is the JSON representation of v, decoded into maps, slices and primitive values,
+// so that any value that can be audited can be redacted. Values that can't be represented as JSON are formatted as strings.
+func (r *Rules) Value(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return r.String(fmt.Sprintf("%v", v))
+	}
+	var generic interface{}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	if err := d.Decode(&generic); err != nil {
+		return r.String(fmt.Sprintf("%v", v))
+	}
+	return r.redact(generic)
+}
+
+func (r *Rules) redact(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for key, value := range t {
+			if value != nil && r.isSecretKey(key) {
+				t[key] = Mask
+			} else {
+				t[key] = r.redact(value)
+			}
+		}
+	case []interface{}:
+		for i := range t {
+			t[i] = r.redact(t[i])
+		}
+	case string:
+		return r.String(t)
+	}
+	return v
+}
+
+func (r *Rules) isSecretKey(key string) bool {
+	for _, k := range r.keys {
+		if k.MatchString(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// Struct returns a copy of v in which every non-empty string field tagged `secret:"true"` is replaced by Mask,
+// for example before a config struct is logged. The copy has the same type as v, and v is not modified.
+func Struct(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	return copyValue(reflect.ValueOf(v)).Interface()
+}
+
+func copyValue(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Struct:
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if field.PkgPath != "" {
+				continue // Unexported fields are not written by the encoders, so are left as they are
+			}
+			if field.Tag.Get("secret") == "true" && field.Type.Kind() == reflect.String {
+				if v.Field(i).Len() > 0 {
+					c.Field(i).SetString(Mask)
+				}
+				continue
+			}
+			c.Field(i).Set(copyValue(v.Field(i)))
+		}
+		return c
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(copyValue(v.Index(i)))
+		}
+		return c
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			c.SetMapIndex(iter.Key(), copyValue(iter.Value()))
+		}
+		return c
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type().Elem())
+		c.Elem().Set(copyValue(v.Elem()))
+		return c
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type()).Elem()
+		c.Set(copyValue(v.Elem()))
+		return c
+	}
+	return v
+}
+
+// writer redacts everything written to it before passing it on
+type writer struct {
+	w io.Writer
+}
+
+// NewWriter returns a writer that redacts secrets using the current rules before writing to w.
+// Each write is redacted on its own, as the log package writes one entry at a time.
+func NewWriter(w io.Writer) io.Writer {
+	return &writer{w: w}
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	if _, err := w.w.Write([]byte(String(string(p)))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,151 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// cucumberFeature is a feature in the results written by godog's cucumber formatter
+type cucumberFeature struct {
+	URI         string            `json:"uri"`
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Tags        []cucumberTag     `json:"tags"`
+	Elements    []cucumberElement `json:"elements"`
+}
+
+type cucumberTag struct {
+	Name string `json:"name"`
+}
+
+// cucumberElement is a scenario, or an example of a scenario outline
+type cucumberElement struct {
+	Name  string         `json:"name"`
+	Line  int            `json:"line"`
+	Type  string         `json:"type"`
+	Tags  []cucumberTag  `json:"tags"`
+	Steps []cucumberStep `json:"steps"`
+}
+
+type cucumberStep struct {
+	Keyword string `json:"keyword"`
+	Name    string `json:"name"`
+	Line    int    `json:"line"`
+	Result  struct {
+		Status   string `json:"status"` // passed / failed / skipped / undefined / pending
+		Error    string `json:"error_message"`
+		Duration int64  `json:"duration"` // Nanoseconds
+	} `json:"result"`
+}
+
+// cucumberResults are the features run by a probe. Scenarios that were retried hold the result of their last attempt.
+type cucumberResults struct {
+	features []*cucumberFeature
+	attempts map[string]int // Number of attempts of each retried scenario, see attemptKey
+}
+
+// attemptFile matches the results of a retried attempt, see probeengine.GodogProbeHandler
+var attemptFile = regexp.MustCompile(`^(.+)_attempt(\d+)$`)
+
+// readCucumber reads the cucumber results of each probe in dir, keyed by probe name.
+// Results in other formats, such as <probe>.junit.xml, are ignored.
+func readCucumber(dir string) (map[string]*cucumberResults, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	type resultsFile struct {
+		path    string
+		attempt int
+	}
+	files := make(map[string][]resultsFile)
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), ".json")
+		if strings.Contains(name, ".") {
+			continue
+		}
+		attempt := 1
+		if m := attemptFile.FindStringSubmatch(name); m != nil {
+			name = m[1]
+			attempt, _ = strconv.Atoi(m[2])
+		}
+		files[name] = append(files[name], resultsFile{path: path, attempt: attempt})
+	}
+
+	results := make(map[string]*cucumberResults)
+	for name, probeFiles := range files {
+		sort.Slice(probeFiles, func(i, j int) bool { return probeFiles[i].attempt < probeFiles[j].attempt })
+		r := &cucumberResults{attempts: make(map[string]int)}
+		for _, f := range probeFiles {
+			data, err := ioutil.ReadFile(f.path)
+			if err != nil {
+				return nil, err
+			}
+			var features []*cucumberFeature
+			if len(strings.TrimSpace(string(data))) > 0 {
+				if err := json.Unmarshal(data, &features); err != nil {
+					return nil, fmt.Errorf("could not parse cucumber results '%s': %v", f.path, err)
+				}
+			}
+			r.add(features)
+		}
+		results[name] = r
+	}
+	return results, nil
+}
+
+// add records the results of an attempt. Retried attempts only run the scenarios that failed,
+// so each retried scenario replaces the result of its previous attempt.
+func (r *cucumberResults) add(features []*cucumberFeature) {
+	for _, feature := range features {
+		existing := r.feature(feature.URI)
+		if existing == nil {
+			r.features = append(r.features, feature)
+			continue
+		}
+		for _, element := range feature.Elements {
+			key := attemptKey(feature.URI, element)
+			replaced := false
+			for i := range existing.Elements {
+				if existing.Elements[i].Name == element.Name && existing.Elements[i].Line == element.Line {
+					existing.Elements[i] = element
+					replaced = true
+					break
+				}
+			}
+			if !replaced {
+				existing.Elements = append(existing.Elements, element)
+			}
+			if r.attempts[key] == 0 {
+				r.attempts[key] = 1
+			}
+			r.attempts[key]++
+		}
+	}
+}
+
+func attemptKey(uri string, element cucumberElement) string {
+	return fmt.Sprintf("%s:%d", uri, element.Line)
+}
+
+func (r *cucumberResults) feature(uri string) *cucumberFeature {
+	for _, f := range r.features {
+		if f.URI == uri {
+			return f
+		}
+	}
+	return nil
+}
+
+func tagNames(tags []cucumberTag) (names []string) {
+	for _, t := range tags {
+		names = append(names, t.Name)
+	}
+	return
+}
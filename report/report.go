@@ -0,0 +1,383 @@
+// Package report renders a self-contained HTML report of a run from its summary, audits and cucumber results.
+// The report has no external dependencies, so it can be archived alongside the results in the run directory.
+package report
+
+import (
+	"bytes"
+	_ "embed" // Required for go:embed
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	sdk "github.com/citihub/probr-sdk"
+	"github.com/citihub/probr-sdk/audit"
+	"github.com/citihub/probr-sdk/config"
+	"github.com/citihub/probr-sdk/utils"
+)
+
+// FileName is the name of the report written to the run directory, see Write
+const FileName = "report.html"
+
+//go:embed report.html.tmpl
+var reportTemplate string
+
+var tmpl = template.Must(template.New("report").Funcs(template.FuncMap{
+	"duration": formatDuration,
+	"time":     func(t time.Time) string { return t.Format("2006-01-02 15:04:05 MST") },
+}).Parse(reportTemplate))
+
+// Report is the content of an HTML report
+type Report struct {
+	Metadata
+	Generated time.Time
+	Totals    Totals
+	Features  []*Feature // Ordered by probe
+}
+
+// Metadata describes the run a report was generated for
+type Metadata struct {
+	Pack      string
+	StartTime time.Time
+	Config    []Setting // Summary of the config the run used
+}
+
+// Setting is a config value shown in a report
+type Setting struct {
+	Name  string
+	Value string
+}
+
+// Totals are the number of probes and scenarios in a report with each result
+type Totals struct {
+	Probes           int
+	ProbesFailed     int
+	Scenarios        int
+	ScenariosPassed  int
+	ScenariosFailed  int
+	ScenariosSkipped int
+}
+
+// Feature is a feature file run by a probe
+type Feature struct {
+	Probe       string
+	Name        string
+	Description string
+	URI         string
+	Tags        []string
+	Error       string // Set if the probe could not be completed
+	Scenarios   []*Scenario
+}
+
+// Scenario is a scenario run by a probe, with the audit of its final attempt
+type Scenario struct {
+	Name     string
+	Result   string // Passed / Passed After Retry / Failed / Given Not Met / Skipped
+	Tags     []string
+	Attempts int
+	Duration time.Duration
+	Steps    []*Step
+}
+
+// Step is a step of a scenario, including the description and payload audited by the step
+type Step struct {
+	Keyword     string
+	Name        string
+	Result      string // passed / failed / skipped / undefined / pending
+	Error       string
+	Duration    time.Duration
+	Description string
+	Payload     string // Indented JSON
+}
+
+// Write writes a report of the probes in the summary of the current run to its run directory (see
+// sdk.GlobalOpts.OutputDir), using the cucumber results the probe engine wrote there and the config and start time of the run.
+func Write(summary *audit.SummaryState, packName string) error {
+	dir := sdk.GlobalConfig.OutputDir()
+	r, err := New(summary, filepath.Join(dir, "cucumber"), Metadata{
+		Pack:      packName,
+		StartTime: sdk.GlobalConfig.StartTime,
+		Config:    configSettings(),
+	})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	path := filepath.Join(dir, FileName)
+	if !utils.WriteAllowed(path) {
+		return nil
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := r.Render(f); err != nil {
+		return err
+	}
+	log.Printf("[NOTICE] HTML report written to file %s", path)
+	return nil
+}
+
+// Build reads a run directory, as written by audit.SummaryState.WriteSummary. The probes reported are those in its
+// summary.json, with their audits and the cucumber results in its cucumber directory; see New.
+func Build(dir string, meta Metadata) (*Report, error) {
+	summary, err := audit.MergeSummaries(filepath.Join(dir, "summary.json"))
+	if err != nil {
+		return nil, err
+	}
+	return New(summary, filepath.Join(dir, "cucumber"), meta)
+}
+
+// New builds a report of the probes in the summary. Scenarios are taken from the cucumber results in cucumberDir,
+// with the description and payload of each step taken from the audit; probes without cucumber results, e.g. those
+// that timed out, are reported from their audit alone. Results of probes that are not in the summary are ignored.
+func New(summary *audit.SummaryState, cucumberDir string, meta Metadata) (*Report, error) {
+	results, err := readCucumber(cucumberDir)
+	if err != nil {
+		return nil, err
+	}
+	audits := summary.Snapshot().Probes
+	var probes []string
+	for name, p := range audits {
+		if results[name] != nil || len(p.Scenarios) > 0 || p.Error != "" { // Excluded probes have nothing to report
+			probes = append(probes, name)
+		}
+	}
+	sort.Strings(probes)
+
+	r := &Report{Metadata: meta, Generated: time.Now()}
+	for _, name := range probes {
+		var features []*Feature
+		if results[name] != nil {
+			features = cucumberFeatures(name, results[name], audits[name])
+		} else {
+			features = []*Feature{auditFeature(name, audits[name])}
+		}
+		if p := audits[name]; p.Error != "" && len(features) > 0 {
+			features[0].Error = p.Error
+		}
+		r.add(features)
+		r.Features = append(r.Features, features...)
+	}
+	return r, nil
+}
+
+// Render writes the report as HTML to w
+func (r *Report) Render(w io.Writer) error {
+	return tmpl.Execute(w, r)
+}
+
+// add counts the probe's features in the totals
+func (r *Report) add(features []*Feature) {
+	r.Totals.Probes++
+	failed := false
+	for _, f := range features {
+		failed = failed || f.Error != ""
+		for _, s := range f.Scenarios {
+			r.Totals.Scenarios++
+			switch s.Status() {
+			case "passed":
+				r.Totals.ScenariosPassed++
+			case "failed":
+				r.Totals.ScenariosFailed++
+				failed = true
+			default:
+				r.Totals.ScenariosSkipped++
+			}
+		}
+	}
+	if failed {
+		r.Totals.ProbesFailed++
+	}
+}
+
+// Status is passed, failed or skipped, for styling
+func (f *Feature) Status() string {
+	status := "skipped"
+	if f.Error != "" {
+		return "failed"
+	}
+	for _, s := range f.Scenarios {
+		switch s.Status() {
+		case "failed":
+			return "failed"
+		case "passed":
+			status = "passed"
+		}
+	}
+	return status
+}
+
+// Status is passed, failed or skipped, for styling. Scenarios whose 'given' was not met are skipped.
+func (s *Scenario) Status() string {
+	switch s.Result {
+	case "Passed", "Passed After Retry":
+		return "passed"
+	case "Failed":
+		return "failed"
+	}
+	return "skipped"
+}
+
+// cucumberFeatures converts the cucumber results of a probe, matching each scenario to its audit by name and occurrence
+func cucumberFeatures(probeName string, results *cucumberResults, p *audit.Probe) (features []*Feature) {
+	occurrences := make(map[string]int)
+	for _, cf := range results.features {
+		f := &Feature{
+			Probe:       probeName,
+			Name:        cf.Name,
+			Description: strings.TrimSpace(cf.Description),
+			URI:         cf.URI,
+			Tags:        tagNames(cf.Tags),
+		}
+		for _, element := range cf.Elements {
+			if element.Type == "background" {
+				continue
+			}
+			occurrences[element.Name]++
+			audited := auditScenario(p, element.Name, occurrences[element.Name])
+			s := &Scenario{
+				Name:     element.Name,
+				Tags:     tagNames(element.Tags),
+				Attempts: results.attempts[attemptKey(cf.URI, element)],
+				Result:   "Passed",
+			}
+			for i, cs := range element.Steps {
+				step := &Step{
+					Keyword:  strings.TrimSpace(cs.Keyword),
+					Name:     cs.Name,
+					Result:   cs.Result.Status,
+					Error:    cs.Result.Error,
+					Duration: time.Duration(cs.Result.Duration),
+				}
+				if audited != nil {
+					step.addAudit(auditStep(audited, cs.Name, i))
+				}
+				s.Duration += step.Duration
+				s.Steps = append(s.Steps, step)
+				if s.Result == "Passed" && step.Result != "passed" {
+					s.Result = "Skipped"
+					if step.Result == "failed" {
+						s.Result = "Failed"
+					}
+				}
+			}
+			if audited != nil && audited.Result != "" {
+				s.Result = audited.Result // Distinguishes retried scenarios, and those whose 'given' was not met
+			}
+			f.Scenarios = append(f.Scenarios, s)
+		}
+		features = append(features, f)
+	}
+	return
+}
+
+// auditFeature converts the audit of a probe that has no cucumber results
+func auditFeature(probeName string, p *audit.Probe) *Feature {
+	f := &Feature{Probe: probeName, Name: probeName}
+	for _, as := range p.Scenarios {
+		s := &Scenario{
+			Name:     as.Name,
+			Result:   as.Result,
+			Tags:     as.Tags,
+			Attempts: len(as.PreviousAttempts) + 1,
+			Duration: as.Duration,
+		}
+		if s.Attempts == 1 {
+			s.Attempts = 0 // Only retried scenarios show their attempts, as for cucumber results
+		}
+		for _, as := range as.Steps {
+			step := &Step{Name: as.Name, Result: strings.ToLower(as.Result), Error: as.Error, Duration: as.Duration}
+			step.addAudit(as.Description, as.Payload)
+			s.Steps = append(s.Steps, step)
+		}
+		f.Scenarios = append(f.Scenarios, s)
+	}
+	return f
+}
+
+// auditScenario returns the nth scenario of the given name in the audit, if any
+func auditScenario(p *audit.Probe, name string, n int) *audit.Scenario {
+	if p == nil {
+		return nil
+	}
+	for _, s := range p.Scenarios {
+		if s.Name == name {
+			if n--; n == 0 {
+				return s
+			}
+		}
+	}
+	return nil
+}
+
+// auditStep returns the description and payload of the audited step with the given name,
+// or of the step at index i if the step was audited under a different name
+func auditStep(s *audit.Scenario, name string, i int) (string, interface{}) {
+	for _, step := range s.Steps {
+		if step.Name == name {
+			return step.Description, step.Payload
+		}
+	}
+	if i < len(s.Steps) {
+		return s.Steps[i].Description, s.Steps[i].Payload
+	}
+	return "", nil
+}
+
+func (s *Step) addAudit(description string, payload interface{}) {
+	s.Description = description
+	if payload == nil {
+		return
+	}
+	var buf bytes.Buffer
+	e := json.NewEncoder(&buf)
+	e.SetEscapeHTML(false) // Escaped by the template
+	e.SetIndent("", "  ")
+	if err := e.Encode(payload); err == nil {
+		s.Payload = strings.TrimSpace(buf.String())
+	} else {
+		s.Payload = fmt.Sprintf("%v", payload)
+	}
+}
+
+// configSettings summarises the config of the current run
+func configSettings() (settings []Setting) {
+	v := config.Vars
+	add := func(name, value string) {
+		if value != "" {
+			settings = append(settings, Setting{Name: name, Value: value})
+		}
+	}
+	add("Run", strings.Join(v.Run, ", "))
+	add("Tags", v.Tags)
+	add("Tag exclusions", strings.Join(v.TagExclusions, ", "))
+	add("Output type", v.OutputType)
+	add("Results formats", strings.Join(v.ResultsFormats, ", "))
+	add("Max concurrent probes", v.MaxConcurrentProbes)
+	add("Probe timeout", v.ProbeTimeout)
+	add("Run timeout", v.RunTimeout)
+	add("Retry max attempts", v.Retry.MaxAttempts)
+	if v.ShardTotal != "" && v.ShardTotal != "1" {
+		add("Shard", fmt.Sprintf("%s/%s", v.ShardIndex, v.ShardTotal))
+	}
+	add("Dry run", v.DryRun)
+	add("Log level", v.LogLevel)
+	return
+}
+
+func formatDuration(d time.Duration) string {
+	if d < time.Millisecond {
+		return d.String()
+	}
+	return d.Round(time.Millisecond).String()
+}
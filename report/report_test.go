@@ -0,0 +1,185 @@
+package report
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	sdk "github.com/citihub/probr-sdk"
+	"github.com/citihub/probr-sdk/audit"
+	"github.com/citihub/probr-sdk/config"
+)
+
+const cucumberResultsFile = `[{
+  "uri": "probes/kubernetes/pod_security.feature",
+  "name": "Pod Security",
+  "description": "  Ensure pods are secure  ",
+  "tags": [{"name": "@k-psp"}],
+  "elements": [
+    {"name": "privileged pods are denied", "line": 5, "type": "scenario", "tags": [{"name": "@k-psp-001"}], "steps": [
+      {"keyword": "Given ", "name": "a cluster exists", "line": 6, "result": {"status": "passed", "duration": 1000000}},
+      {"keyword": "Then ", "name": "the pod is denied", "line": 7, "result": {"status": "failed", "error_message": "pod was created", "duration": 2000000}}
+    ]},
+    {"name": "host network is denied", "line": 9, "type": "scenario", "steps": [
+      {"keyword": "Given ", "name": "a cluster exists", "line": 10, "result": {"status": "passed"}},
+      {"keyword": "Then ", "name": "the pod is denied", "line": 11, "result": {"status": "failed", "error_message": "timed out"}}
+    ]}
+  ]
+}]`
+
+const cucumberAttemptFile = `[{
+  "uri": "probes/kubernetes/pod_security.feature",
+  "name": "Pod Security",
+  "elements": [
+    {"name": "host network is denied", "line": 9, "type": "scenario", "steps": [
+      {"keyword": "Given ", "name": "a cluster exists", "line": 10, "result": {"status": "passed"}},
+      {"keyword": "Then ", "name": "the pod is denied", "line": 11, "result": {"status": "passed"}}
+    ]}
+  ]
+}]`
+
+const auditFile = `{
+  "SchemaVersion": 2,
+  "Result": "Failed",
+  "Scenarios": [
+    {"Name": "privileged pods are denied", "Result": "Failed", "Steps": [
+      {"Name": "a cluster exists", "Result": "Passed", "Description": "Cluster is reachable"},
+      {"Name": "the pod is denied", "Result": "Failed", "Error": "pod was created", "Payload": {"PodName": "probr-<psp>"}}
+    ]},
+    {"Name": "host network is denied", "Result": "Passed After Retry", "Steps": []}
+  ]
+}`
+
+const timedOutAuditFile = `{
+  "SchemaVersion": 2,
+  "Error": "probe timed out",
+  "Scenarios": [
+    {"Name": "logs are collected", "Result": "Failed", "Steps": [
+      {"Name": "a cluster exists", "Result": "Failed", "Error": "no cluster"}
+    ]}
+  ]
+}`
+
+const summaryFile = `{
+  "SchemaVersion": 2,
+  "Probes": [
+    {"Name": "excluded", "Path": "", "Result": "Excluded"},
+    {"Name": "logging", "Path": "/moved/audit/logging.json", "Result": "Error"},
+    {"Name": "pod_security", "Path": "/moved/audit/pod_security.json", "Result": "Failed"}
+  ]
+}`
+
+func writeTestFile(t *testing.T, dir, name, content string) {
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBuild(t *testing.T) {
+	dir, err := ioutil.TempDir("", "probr-report")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeTestFile(t, dir, "cucumber/pod_security.json", cucumberResultsFile)
+	writeTestFile(t, dir, "cucumber/pod_security_attempt2.json", cucumberAttemptFile)
+	writeTestFile(t, dir, "cucumber/pod_security.junit.xml", "<testsuites/>")
+	writeTestFile(t, dir, "audit/pod_security.json", auditFile)
+	writeTestFile(t, dir, "audit/logging.json", timedOutAuditFile)
+	writeTestFile(t, dir, "summary.json", summaryFile)
+	// Results of probes that are not in the summary, e.g. those left by an earlier run, are not reported
+	writeTestFile(t, dir, "cucumber/stale.json", cucumberAttemptFile)
+	writeTestFile(t, dir, "audit/stale.json", auditFile)
+
+	start := time.Date(2021, 3, 1, 9, 30, 0, 0, time.UTC)
+	r, err := Build(dir, Metadata{Pack: "kubernetes", StartTime: start, Config: []Setting{{Name: "Tags", Value: "@k-psp"}}})
+	if err != nil {
+		t.Fatalf("Build() returned unexpected error: %v", err)
+	}
+
+	expectedTotals := Totals{Probes: 2, ProbesFailed: 2, Scenarios: 3, ScenariosPassed: 1, ScenariosFailed: 2}
+	if r.Totals != expectedTotals {
+		t.Errorf("Totals = %+v, Expected: %+v", r.Totals, expectedTotals)
+	}
+	if len(r.Features) != 2 || r.Features[0].Probe != "logging" || r.Features[1].Probe != "pod_security" {
+		t.Fatalf("Expected a feature for each probe, ordered by probe, but found %+v", r.Features)
+	}
+	if r.Features[0].Error != "probe timed out" || r.Features[0].Scenarios[0].Steps[0].Error != "no cluster" {
+		t.Errorf("Expected probe without cucumber results to be reported from its audit, but found %+v", r.Features[0])
+	}
+
+	feature := r.Features[1]
+	if feature.Name != "Pod Security" || feature.Description != "Ensure pods are secure" {
+		t.Errorf("Expected feature to be read from cucumber results, but found %+v", feature)
+	}
+	failed, retried := feature.Scenarios[0], feature.Scenarios[1]
+	if failed.Result != "Failed" || failed.Duration != 3*time.Millisecond || failed.Steps[1].Keyword != "Then" {
+		t.Errorf("Expected failed scenario to be read from cucumber results, but found %+v", failed)
+	}
+	if failed.Steps[0].Description != "Cluster is reachable" || !strings.Contains(failed.Steps[1].Payload, `"PodName": "probr-<psp>"`) {
+		t.Errorf("Expected steps to include their audited description and payload, but found %+v and %+v", failed.Steps[0], failed.Steps[1])
+	}
+	if retried.Result != "Passed After Retry" || retried.Attempts != 2 || retried.Steps[1].Result != "passed" {
+		t.Errorf("Expected retried scenario to hold the result of its last attempt, but found %+v", retried)
+	}
+
+	var buf bytes.Buffer
+	if err := r.Render(&buf); err != nil {
+		t.Fatalf("Render() returned unexpected error: %v", err)
+	}
+	html := buf.String()
+	for _, expected := range []string{"kubernetes", "2021-03-01 09:30:00 UTC", "@k-psp", "Cluster is reachable", "probr-&lt;psp&gt;", "Passed After Retry"} {
+		if !strings.Contains(html, expected) {
+			t.Errorf("Expected report to contain '%s'", expected)
+		}
+	}
+	if strings.Contains(html, "<script") || strings.Contains(html, "<link") {
+		t.Errorf("Expected report to be self-contained")
+	}
+}
+
+func TestWrite_ShouldReportProbesInSummaryToRunDirectory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "probr-report")
+	if err != nil {
+		t.Fatal(err)
+	}
+	installDir := sdk.GlobalConfig.InstallDir
+	config.Vars.WriteDirectory = filepath.Join(dir, "write")
+	sdk.GlobalConfig.InstallDir = filepath.Join(dir, "install")
+	defer func() {
+		os.RemoveAll(dir) // Delete test data after tests
+		config.Vars.WriteDirectory = ""
+		sdk.GlobalConfig.InstallDir = installDir
+	}()
+
+	runDir := sdk.GlobalConfig.OutputDir()
+	writeTestFile(t, runDir, "cucumber/pod_security.json", cucumberResultsFile)
+	writeTestFile(t, runDir, "cucumber/stale.json", cucumberAttemptFile)
+	writeTestFile(t, config.Vars.AuditDir(), "stale.json", auditFile)
+	summary := audit.NewSummaryState("kubernetes")
+	summary.GetProbeLog("pod_security").InitializeAuditor("privileged pods are denied", nil).AuditScenarioStep("a cluster exists", "Cluster is reachable", nil, nil)
+	summary.ProbeComplete("pod_security")
+
+	if err := Write(&summary, "kubernetes"); err != nil {
+		t.Fatalf("Write() returned unexpected error: %v", err)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(runDir, FileName))
+	if err != nil {
+		t.Fatalf("Expected report to be written to the run directory: %v", err)
+	}
+	html := string(data)
+	if !strings.Contains(html, "Pod Security") || !strings.Contains(html, "Cluster is reachable") {
+		t.Errorf("Expected report to include the cucumber results and audit of the probe in the summary")
+	}
+	if strings.Contains(html, "stale") {
+		t.Errorf("Expected report to exclude probes that are not in the summary")
+	}
+}
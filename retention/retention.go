@@ -0,0 +1,186 @@
+// Package retention deletes the run directories that each run creates under the output directory,
+// according to a policy limiting their number, age and combined size.
+// The write directory (see config.VarOptions.GetWriteDirectory) is out of scope: each run overwrites its summary and
+// audits there rather than adding to them, and keeps its own copy in its run directory.
+package retention
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	sdk "github.com/citihub/probr-sdk"
+	"github.com/citihub/probr-sdk/config"
+	"github.com/citihub/probr-sdk/utils"
+)
+
+// Policy limits the run directories that are kept. Zero values mean no limit.
+type Policy struct {
+	KeepRuns     int           // Number of most recent runs to keep
+	MaxAge       time.Duration // Runs last modified longer ago than this are deleted
+	MaxTotalSize int64         // Bytes; the oldest runs are deleted until the remainder fit
+}
+
+// Run is a run directory, and the reason it would be deleted by a policy
+type Run struct {
+	Path     string
+	Modified time.Time // Most recent modification of any file in the run
+	Size     int64
+	Reason   string `json:",omitempty"`
+}
+
+// ConfiguredPolicy returns the policy set by the Retention config options
+func ConfiguredPolicy() Policy {
+	return Policy{
+		KeepRuns:     config.Vars.GetRetentionKeepRuns(),
+		MaxAge:       config.Vars.GetRetentionMaxAge(),
+		MaxTotalSize: config.Vars.GetRetentionMaxTotalSize(),
+	}
+}
+
+// OutputRoot is the directory that run directories are created in, see sdk.GlobalOpts.OutputDir
+func OutputRoot() string {
+	return filepath.Join(sdk.GlobalConfig.InstallDir, "output")
+}
+
+// PruneOnStart applies the configured policy to the output directory if Retention.PruneOnStart is set.
+// It is called by probeengine.ProbeStore.ExecAllProbes, before the current run writes any output, and never by a dry run.
+func PruneOnStart() {
+	if strings.ToLower(config.Vars.Retention.PruneOnStart) != "true" {
+		return
+	}
+	if _, err := Prune(OutputRoot(), ConfiguredPolicy(), false, currentRun()...); err != nil {
+		log.Printf("[ERROR] Run directories were not pruned: %v", err)
+	}
+}
+
+// currentRun returns the directories written to by the current run, which are never deleted
+func currentRun() []string {
+	return []string{sdk.GlobalConfig.OutputDir(), filepath.Join(config.Vars.WriteDirectory, utils.GetExecutableName())}
+}
+
+// Prune deletes the run directories in root that the policy does not keep, or only lists them if dryRun is set.
+// Runs that contain, or are within, any of the protected paths are never deleted; by default, these are the
+// directories of the current run. Date directories left empty are also deleted.
+func Prune(root string, policy Policy, dryRun bool, protected ...string) ([]Run, error) {
+	if len(protected) == 0 {
+		protected = currentRun()
+	}
+	runs, err := Runs(root)
+	if err != nil {
+		return nil, err
+	}
+	deleted := plan(runs, policy, time.Now(), protected)
+	for _, run := range deleted {
+		if dryRun {
+			log.Printf("[NOTICE] Dry run: would delete %s (%s)", run.Path, run.Reason)
+			continue
+		}
+		if err := os.RemoveAll(run.Path); err != nil {
+			return nil, err
+		}
+		log.Printf("[NOTICE] Deleted %s (%s)", run.Path, run.Reason)
+		parent := filepath.Dir(run.Path)
+		if entries, err := os.ReadDir(parent); err == nil && len(entries) == 0 && !isProtected(parent, protected) {
+			os.Remove(parent)
+		}
+	}
+	return deleted, nil
+}
+
+// Runs returns the run directories in root, which are nested by date and run ID, from the most recently modified
+func Runs(root string) ([]Run, error) {
+	dates, err := os.ReadDir(root)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var runs []Run
+	for _, date := range dates {
+		if !date.IsDir() {
+			continue
+		}
+		times, err := os.ReadDir(filepath.Join(root, date.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range times {
+			if !t.IsDir() {
+				continue
+			}
+			run, err := readRun(filepath.Join(root, date.Name(), t.Name()))
+			if err != nil {
+				return nil, err
+			}
+			runs = append(runs, run)
+		}
+	}
+	sort.SliceStable(runs, func(i, j int) bool { return runs[i].Modified.After(runs[j].Modified) })
+	return runs, nil
+}
+
+func readRun(path string) (Run, error) {
+	run := Run{Path: path}
+	err := filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.ModTime().After(run.Modified) {
+			run.Modified = info.ModTime()
+		}
+		if info.Mode().IsRegular() {
+			run.Size += info.Size()
+		}
+		return nil
+	})
+	return run, err
+}
+
+// plan returns the runs, ordered from the most recent, that the policy does not keep
+func plan(runs []Run, policy Policy, now time.Time, protected []string) (deleted []Run) {
+	var kept int
+	var keptSize int64
+	for _, run := range runs {
+		switch {
+		case isProtected(run.Path, protected):
+			// Kept, and counted against the limits of older runs
+		case policy.KeepRuns > 0 && kept >= policy.KeepRuns:
+			run.Reason = fmt.Sprintf("only the %d most recent runs are kept", policy.KeepRuns)
+		case policy.MaxAge > 0 && now.Sub(run.Modified) > policy.MaxAge:
+			run.Reason = fmt.Sprintf("older than %s", policy.MaxAge)
+		case policy.MaxTotalSize > 0 && keptSize+run.Size > policy.MaxTotalSize:
+			run.Reason = fmt.Sprintf("runs may not exceed %d bytes in total", policy.MaxTotalSize)
+		}
+		if run.Reason != "" {
+			deleted = append(deleted, run)
+			continue
+		}
+		kept++
+		keptSize += run.Size
+	}
+	return
+}
+
+// isProtected is true if path is, contains, or is within any of the protected paths
+func isProtected(path string, protected []string) bool {
+	for _, p := range protected {
+		if p == "" {
+			continue
+		}
+		if within(path, p) || within(p, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// within is true if path is dir or is within dir
+func within(path, dir string) bool {
+	rel, err := filepath.Rel(filepath.Clean(dir), filepath.Clean(path))
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,128 @@
+package retention
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/citihub/probr-sdk/config"
+)
+
+// createRuns creates a run directory for each name, containing a file of the given size,
+// and each last modified a day before the previous one
+func createRuns(t *testing.T, root string, size int, names ...string) {
+	modified := time.Now()
+	for _, name := range names {
+		dir := filepath.Join(root, name)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dir, "summary.json")
+		if err := ioutil.WriteFile(path, make([]byte, size), 0644); err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range []string{path, dir} {
+			if err := os.Chtimes(p, modified, modified); err != nil {
+				t.Fatal(err)
+			}
+		}
+		modified = modified.Add(-24 * time.Hour)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	runs := []string{"2021315/93000", "2021314/93000", "2021314/80000", "2021313/93000"} // From the most recent
+	tests := []struct {
+		testName  string
+		policy    Policy
+		protected string
+		expected  []string // Runs deleted
+	}{
+		{
+			testName: "Prune_WithoutLimits_ShouldKeepAllRuns",
+		},
+		{
+			testName: "Prune_WithKeepRuns_ShouldDeleteOldestRuns",
+			policy:   Policy{KeepRuns: 2},
+			expected: runs[2:],
+		},
+		{
+			testName: "Prune_WithMaxAge_ShouldDeleteOlderRuns",
+			policy:   Policy{MaxAge: 36 * time.Hour},
+			expected: runs[2:],
+		},
+		{
+			testName: "Prune_WithMaxTotalSize_ShouldDeleteRunsThatDoNotFit",
+			policy:   Policy{MaxTotalSize: 2500},
+			expected: runs[2:],
+		},
+		{
+			testName:  "Prune_WithCurrentRun_ShouldNeverDeleteCurrentRun",
+			policy:    Policy{KeepRuns: 1},
+			protected: runs[3],
+			expected:  runs[1:3],
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			root, err := ioutil.TempDir("", "probr-output")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(root)
+			createRuns(t, root, 1000, runs...)
+			protected := filepath.Join(root, "current")
+			if tt.protected != "" {
+				protected = filepath.Join(root, tt.protected, "kubernetes") // The current run writes within its run directory
+			}
+
+			for _, dryRun := range []bool{true, false} {
+				deleted, err := Prune(root, tt.policy, dryRun, protected)
+				if err != nil {
+					t.Fatalf("Prune() returned unexpected error: %v", err)
+				}
+				var paths []string
+				for _, run := range deleted {
+					paths = append(paths, strings.TrimPrefix(filepath.ToSlash(run.Path), filepath.ToSlash(root)+"/"))
+					if run.Reason == "" {
+						t.Errorf("Expected a reason for deleting %s", run.Path)
+					}
+				}
+				if strings.Join(paths, ",") != strings.Join(tt.expected, ",") {
+					t.Errorf("Prune(dryRun: %v) deleted %v, Expected: %v", dryRun, paths, tt.expected)
+				}
+				for _, run := range runs {
+					_, err := os.Stat(filepath.Join(root, run))
+					wasDeleted := !dryRun && strings.Contains(strings.Join(tt.expected, ","), run)
+					if os.IsNotExist(err) != wasDeleted {
+						t.Errorf("Prune(dryRun: %v): expected %s to exist: %v", dryRun, run, !wasDeleted)
+					}
+				}
+			}
+			if tt.policy.KeepRuns == 2 {
+				if _, err := os.Stat(filepath.Join(root, "2021313")); !os.IsNotExist(err) {
+					t.Errorf("Expected empty date directory to be deleted")
+				}
+			}
+		})
+	}
+}
+
+func TestConfiguredPolicy(t *testing.T) {
+	defer func() {
+		config.Vars.Retention = config.Retention{}
+	}()
+	config.Vars.Retention = config.Retention{KeepRuns: "10", MaxAge: "720h", MaxTotalSize: "1.5GB"}
+	expected := Policy{KeepRuns: 10, MaxAge: 720 * time.Hour} // Sizes must be whole numbers
+	if p := ConfiguredPolicy(); p != expected {
+		t.Errorf("ConfiguredPolicy() = %+v, Expected: %+v", p, expected)
+	}
+	config.Vars.Retention = config.Retention{KeepRuns: "none", MaxAge: "-1h", MaxTotalSize: "500mb"}
+	expected = Policy{MaxTotalSize: 500 << 20}
+	if p := ConfiguredPolicy(); p != expected {
+		t.Errorf("ConfiguredPolicy() = %+v, Expected: %+v", p, expected)
+	}
+}
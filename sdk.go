@@ -1,19 +1,28 @@
 package sdk
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 )
 
+// LatestFileName is the name of the file in the output directory that holds the path of the most recent run,
+// relative to the output directory; see GlobalOpts.SetLatest
+const LatestFileName = "latest"
+
 // GlobalOpts provides configurable options that will be used throughout the SDK
 type GlobalOpts struct {
 	InstallDir         string
 	TmpDir             string
 	GodogResultsFormat string
 	StartTime          time.Time
+	RunID              string // Identifies the run; see NewRunID
 }
 
 // GlobalConfig allows certain values to be configured at runtime for the entire SDK
@@ -29,6 +38,7 @@ func init() {
 		GodogResultsFormat: "cucumber",
 		StartTime:          time.Now(),
 	}
+	GlobalConfig.RunID = NewRunID(GlobalConfig.StartTime)
 	SetTmpDir(filepath.Join(home, "probr", "tmp")) // TODO: this needs error handling
 }
 
@@ -51,12 +61,77 @@ func (gc *GlobalOpts) CleanupTmp() {
 	}
 }
 
-// OutputDir parses a filepath based on GlobalOpts.InstallDir and the datetime this was initialized
+// runIDTimeFormat is the format of the start time that begins each run ID
+const runIDTimeFormat = "20060102T150405.000Z"
+
+// NewRunID returns a unique ID for a run started at the provided time, e.g. 20210311T093000.123Z-1a2b3c4d.
+// IDs are ordered by start time when sorted as strings; the random suffix distinguishes runs started at the same time.
+func NewRunID(start time.Time) string {
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		log.Printf("[ERROR] Failed to generate a random run ID suffix: %v", err)
+	}
+	return fmt.Sprintf("%s-%s", start.UTC().Format(runIDTimeFormat), hex.EncodeToString(suffix))
+}
+
+// OutputDir parses a filepath based on GlobalOpts.InstallDir, the UTC date this was initialized and the RunID.
+// A RunID is generated if one has not been set.
 func (gc *GlobalOpts) OutputDir() string {
-	year, month, day := gc.StartTime.Date()
-	hour, min, sec := gc.StartTime.Clock()
-	yearMonthDay := fmt.Sprintf("%d%d%d", year, month, day)
-	timestamp := fmt.Sprintf("%d%d%d", hour, min, sec)
+	if gc.RunID == "" {
+		gc.RunID = NewRunID(gc.StartTime)
+	}
+	return filepath.Join(gc.outputRoot(), gc.StartTime.UTC().Format("2006-01-02"), gc.RunID)
+}
+
+// RunOutputDir returns the output directory of the run with the provided ID, as OutputDir does for the current run.
+// The date of the run is taken from the start time that begins the ID; see NewRunID.
+func (gc *GlobalOpts) RunOutputDir(runID string) (string, error) {
+	start, err := time.Parse(runIDTimeFormat, strings.SplitN(runID, "-", 2)[0])
+	if err != nil || filepath.Base(runID) != runID {
+		return "", fmt.Errorf("'%s' is not a run ID", runID)
+	}
+	return filepath.Join(gc.outputRoot(), start.Format("2006-01-02"), runID), nil
+}
+
+func (gc *GlobalOpts) outputRoot() string {
+	return filepath.Join(gc.InstallDir, "output")
+}
 
-	return filepath.Join(gc.InstallDir, "output", yearMonthDay, timestamp)
+// SetLatest records OutputDir as the most recent run, so that tools can find it with LatestOutputDir.
+// The pointer file is replaced atomically, so readers never see a partial path.
+func (gc *GlobalOpts) SetLatest() error {
+	root := gc.outputRoot()
+	rel, err := filepath.Rel(root, gc.OutputDir())
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(root, LatestFileName+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // Only remains if the rename failed
+	if _, err := tmp.WriteString(filepath.ToSlash(rel) + "\n"); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(root, LatestFileName))
+}
+
+// LatestOutputDir returns the output directory of the most recent run recorded by SetLatest
+func (gc *GlobalOpts) LatestOutputDir() (string, error) {
+	data, err := ioutil.ReadFile(filepath.Join(gc.outputRoot(), LatestFileName))
+	if err != nil {
+		return "", err
+	}
+	rel := filepath.FromSlash(strings.TrimSpace(string(data)))
+	if rel == "" || filepath.IsAbs(rel) || strings.HasPrefix(rel, "..") {
+		return "", fmt.Errorf("'%s' does not hold a path within the output directory", LatestFileName)
+	}
+	return filepath.Join(gc.outputRoot(), rel), nil
 }
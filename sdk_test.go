@@ -1,7 +1,7 @@
 package sdk
 
 import (
-	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
@@ -46,41 +46,97 @@ func TestTmpDir(t *testing.T) {
 }
 
 func TestGlobalOpts_OutputDir(t *testing.T) {
-	time1 := time.Now()
-	time2 := time.Now().Local().AddDate(1, 2, 3)
 	tests := []struct {
-		name string
-		time time.Time
-		base string
+		name     string
+		time     time.Time
+		base     string
+		expected string // Date directory and run ID prefix
 	}{
 		{
-			name: "Test OutputDir",
-			time: time1,
-			base: filepath.Join("imaginary", "dir"),
+			name:     "OutputDir_WithSingleDigitMonth_ShouldZeroPadDate",
+			time:     time.Date(2021, 1, 11, 9, 3, 0, 0, time.UTC),
+			base:     filepath.Join("imaginary", "dir"),
+			expected: filepath.Join("2021-01-11", "20210111T090300.000Z-"),
 		},
 		{
-			name: "Test OutputDir",
-			time: time2,
-			base: filepath.Join("other", "imaginary", "dir"),
+			name:     "OutputDir_WithLocalTime_ShouldUseUTC",
+			time:     time.Date(2021, 11, 1, 23, 30, 0, 0, time.FixedZone("UTC-2", -2*60*60)),
+			base:     filepath.Join("other", "imaginary", "dir"),
+			expected: filepath.Join("2021-11-02", "20211102T013000.000Z-"),
 		},
 	}
+	var dirs []string
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			year, month, day := tt.time.Date()
-			hour, min, sec := tt.time.Clock()
 			gc := GlobalOpts{
 				InstallDir: tt.base,
 				StartTime:  tt.time,
 			}
 			got := gc.OutputDir()
-			if !strings.Contains(got, tt.base) {
-				t.Errorf("Expected output to contain '%s' but found '%s'", tt.base, got)
+			expected := filepath.Join(tt.base, "output", tt.expected)
+			if !strings.HasPrefix(got, expected) {
+				t.Errorf("Expected output to start with '%s' but found '%s'", expected, got)
+			}
+			if again := gc.OutputDir(); again != got {
+				t.Errorf("Expected OutputDir to be stable, but found '%s' then '%s'", got, again)
 			}
-			for _, time := range []int{year, int(month), day, hour, min, sec} {
-				if !strings.Contains(got, fmt.Sprint(time)) {
-					t.Errorf("Expected output to contain '%d' but found '%s'", time, got)
-				}
+			other := GlobalOpts{InstallDir: tt.base, StartTime: tt.time}
+			if other.OutputDir() == got {
+				t.Errorf("Expected runs started at the same time to have different output directories, but both were '%s'", got)
 			}
+			dirs = append(dirs, strings.TrimPrefix(got, tt.base))
 		})
 	}
+	if len(dirs) == 2 && dirs[0] >= dirs[1] {
+		t.Errorf("Expected output directories to sort by start time, but found '%s' after '%s'", dirs[0], dirs[1])
+	}
+}
+
+func TestGlobalOpts_SetLatest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "probr-install")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	gc := GlobalOpts{InstallDir: dir, StartTime: time.Now()}
+	if _, err := gc.LatestOutputDir(); err == nil {
+		t.Errorf("Expected an error before any run was recorded")
+	}
+	for i := 0; i < 2; i++ {
+		gc.RunID = ""
+		if err := gc.SetLatest(); err != nil {
+			t.Fatalf("SetLatest() returned unexpected error: %v", err)
+		}
+		got, err := gc.LatestOutputDir()
+		if err != nil || got != gc.OutputDir() {
+			t.Errorf("LatestOutputDir() = '%s', %v, Expected: '%s'", got, err, gc.OutputDir())
+		}
+	}
+	entries, _ := os.ReadDir(filepath.Join(dir, "output"))
+	if len(entries) != 1 {
+		t.Errorf("Expected only the pointer file in the output directory, but found %d entries", len(entries))
+	}
+
+	latest := filepath.Join(dir, "output", LatestFileName)
+	if err := ioutil.WriteFile(latest, []byte("../../etc\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := gc.LatestOutputDir(); err == nil {
+		t.Errorf("Expected an error for a path outside the output directory, but found '%s'", got)
+	}
+}
+
+func TestGlobalOpts_RunOutputDir(t *testing.T) {
+	base := filepath.Join("imaginary", "dir")
+	gc := GlobalOpts{InstallDir: base, StartTime: time.Date(2021, 11, 1, 23, 30, 0, 0, time.FixedZone("UTC-2", -2*60*60))}
+	expected := gc.OutputDir() // Generates the RunID
+	if got, err := gc.RunOutputDir(gc.RunID); err != nil || got != expected {
+		t.Errorf("RunOutputDir() = '%s', %v, Expected the output directory of the run: '%s'", got, err, expected)
+	}
+	for _, runID := range []string{"", "latest", "2021-11-02", "20211102T013000.000Z-1a2b3c4d/../other"} {
+		if got, err := gc.RunOutputDir(runID); err == nil {
+			t.Errorf("Expected an error for '%s', which is not a run ID, but found '%s'", runID, got)
+		}
+	}
 }
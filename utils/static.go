@@ -0,0 +1,154 @@
+package utils
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// StaticFS is a layered file system used to read feature files and other static assets.
+// Layers are searched from the most recently added, so later layers override earlier ones,
+// and the override directory (if set) takes precedence over all layers.
+type StaticFS struct {
+	lock        sync.RWMutex
+	layers      []*staticLayer
+	overrideDir string
+}
+
+type staticLayer struct {
+	fsys fs.FS
+	dir  string // Set if the layer is a directory on disk
+}
+
+// staticFS falls back to the working directory, matching pkger's behavior when no files have been bundled.
+// As the working directory is on disk, godog reads its feature files in place.
+var staticFS = defaultStaticFS()
+
+func defaultStaticFS() *StaticFS {
+	s := NewStaticFS()
+	s.AddDirLayer(".")
+	return s
+}
+
+// NewStaticFS creates a StaticFS with the provided layers, in order of increasing precedence
+func NewStaticFS(layers ...fs.FS) *StaticFS {
+	s := &StaticFS{}
+	for _, layer := range layers {
+		s.AddLayer(layer)
+	}
+	return s
+}
+
+// GetStaticFS returns the StaticFS used by ReadStaticFile and the probe engine
+func GetStaticFS() *StaticFS {
+	return staticFS
+}
+
+// RegisterStaticFS adds a layer to the StaticFS used by ReadStaticFile and the probe engine,
+// returning a function that removes it again. Service packs should use this to register their
+// embedded feature files and assets, e.g.
+//
+//	//go:embed service_packs
+//	var files embed.FS
+//	utils.RegisterStaticFS(files)
+func RegisterStaticFS(fsys fs.FS) (unregister func()) {
+	return staticFS.AddLayer(fsys)
+}
+
+// SetStaticOverrideDir sets a directory on disk that takes precedence over all registered layers.
+// This allows local copies of feature files to be used for debugging. An empty string removes the override.
+func SetStaticOverrideDir(dir string) {
+	staticFS.SetOverrideDir(dir)
+}
+
+// AddLayer adds fsys as the highest precedence layer, returning a function that removes it again
+func (s *StaticFS) AddLayer(fsys fs.FS) (remove func()) {
+	return s.addLayer(&staticLayer{fsys: fsys})
+}
+
+// AddDirLayer adds a directory on disk as the highest precedence layer, returning a function that removes it again
+func (s *StaticFS) AddDirLayer(dir string) (remove func()) {
+	return s.addLayer(&staticLayer{fsys: os.DirFS(dir), dir: dir})
+}
+
+func (s *StaticFS) addLayer(layer *staticLayer) func() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.layers = append(s.layers, layer)
+	return func() {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		for i, l := range s.layers {
+			if l == layer {
+				s.layers = append(s.layers[:i:i], s.layers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// SetOverrideDir sets a directory on disk that takes precedence over all layers
+func (s *StaticFS) SetOverrideDir(dir string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.overrideDir = dir
+}
+
+// Open opens the named file from the highest precedence layer that contains it, satisfying fs.FS
+func (s *StaticFS) Open(name string) (fs.File, error) {
+	layer, err := s.find(name)
+	if err != nil {
+		return nil, err
+	}
+	return layer.fsys.Open(name)
+}
+
+// ReadFile reads the named file from the highest precedence layer that contains it
+func (s *StaticFS) ReadFile(name string) ([]byte, error) {
+	layer, err := s.find(name)
+	if err != nil {
+		return nil, err
+	}
+	return fs.ReadFile(layer.fsys, name)
+}
+
+// DiskPath returns the path on disk of the named file, if the layer that provides it is a directory on disk
+func (s *StaticFS) DiskPath(name string) (string, bool) {
+	layer, err := s.find(name)
+	if err != nil || layer.dir == "" {
+		return "", false
+	}
+	return filepath.Join(layer.dir, filepath.FromSlash(name)), true
+}
+
+func (s *StaticFS) find(name string) (*staticLayer, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	layers := s.layers
+	if s.overrideDir != "" {
+		layers = append(layers[:len(layers):len(layers)], &staticLayer{fsys: os.DirFS(s.overrideDir), dir: s.overrideDir})
+	}
+	for i := len(layers) - 1; i >= 0; i-- {
+		_, err := fs.Stat(layers[i].fsys, name)
+		if err == nil {
+			return layers[i], nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+// StaticFSPath converts a file path into a name that can be opened from a StaticFS
+func StaticFSPath(filePath string) string {
+	return strings.TrimPrefix(path.Clean(filepath.ToSlash(filePath)), "/")
+}
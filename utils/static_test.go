@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func TestStaticFS(t *testing.T) {
+	testFolder, testFolderErr := filepath.Abs("./testdata")
+	if testFolderErr != nil {
+		t.Fatalf("Error loading test data folder: %v", testFolderErr)
+	}
+	testFileName := "psp-azp-privileges.yaml"
+
+	base := fstest.MapFS{
+		"assets/base.txt":     &fstest.MapFile{Data: []byte("base")},
+		"assets/override.txt": &fstest.MapFile{Data: []byte("base")},
+	}
+	overlay := fstest.MapFS{
+		"assets/override.txt": &fstest.MapFile{Data: []byte("overlay")},
+	}
+	s := NewStaticFS(base, overlay)
+
+	tests := []struct {
+		testName         string
+		name             string
+		overrideDir      string
+		expectedResult   string
+		expectedDiskPath string
+		expectedError    bool
+	}{
+		{
+			testName:       "StaticFS_WithFileInBaseLayer_ShouldReadBaseLayer",
+			name:           "assets/base.txt",
+			expectedResult: "base",
+		},
+		{
+			testName:       "StaticFS_WithFileInOverlay_ShouldReadOverlay",
+			name:           "assets/override.txt",
+			expectedResult: "overlay",
+		},
+		{
+			testName:      "StaticFS_WithMissingFile_ShouldReturnError",
+			name:          "assets/missing.txt",
+			expectedError: true,
+		},
+		{
+			testName:      "StaticFS_WithInvalidPath_ShouldReturnError",
+			name:          "../assets/base.txt",
+			expectedError: true,
+		},
+		{
+			testName:         "StaticFS_WithOverrideDir_ShouldReadFromDisk",
+			name:             testFileName,
+			overrideDir:      testFolder,
+			expectedDiskPath: filepath.Join(testFolder, testFileName),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			s.SetOverrideDir(tt.overrideDir)
+			got, err := s.ReadFile(tt.name)
+			if (err != nil) != tt.expectedError {
+				t.Errorf("ReadFile() error = %v, Expected %v", err, tt.expectedError)
+				return
+			}
+			if tt.expectedResult != "" && string(got) != tt.expectedResult {
+				t.Errorf("ReadFile() = %s, Expected %s", got, tt.expectedResult)
+			}
+			diskPath, found := s.DiskPath(tt.name)
+			if found != (tt.expectedDiskPath != "") || diskPath != tt.expectedDiskPath {
+				t.Errorf("DiskPath() = %v, %v; Expected %v", diskPath, found, tt.expectedDiskPath)
+			}
+		})
+	}
+}
@@ -10,8 +10,6 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
-
-	"github.com/markbates/pkger"
 )
 
 func init() {
@@ -89,6 +87,7 @@ func ReformatError(e string, v ...interface{}) error {
 // Path:
 //  In most cases it will be ReadStaticFile(assetDir, fileName).
 //  It could also be used as ReadStaticFile(assetDir, subfolder, filename)
+// Absolute paths are read from disk, while relative paths are read from the layers registered with RegisterStaticFS
 func ReadStaticFile(path ...string) ([]byte, error) {
 
 	// Validation for empty path
@@ -96,31 +95,11 @@ func ReadStaticFile(path ...string) ([]byte, error) {
 		return nil, ReformatError("Path argument cannot be empty")
 	}
 
-	filename := path[len(path)-1]           // file name is the last string argument
-	dirpathSlice := path[0:(len(path) - 1)] // folder path
-
-	dirPath := ""
-	for _, folder := range dirpathSlice {
-		dirPath = filepath.Join(dirPath, folder)
-	}
-
-	filePath := filepath.Join(dirPath, filename)
-
-	if !filepath.IsAbs(filePath) {
-		filePath = filepath.Join("/", filePath) //Need the abs path (/) for pgker to work
-	}
-
-	// If pkged.go file has been generated using pkger cli tool, this will open the file from bundled memory buffer.
-	// Otherwise, this will read from file system
-	// See: https://github.com/markbates/pkger
-
-	f, err := pkger.Open(filePath)
-	if err != nil {
-		return nil, err
+	filePath := filepath.Join(path...)
+	if filepath.IsAbs(filePath) {
+		return ioutil.ReadFile(filePath)
 	}
-	defer f.Close()
-
-	return ioutil.ReadAll(f)
+	return staticFS.ReadFile(StaticFSPath(filePath))
 }
 
 // ReplaceBytesValue replaces a substring with a new value for a given string in bytes
@@ -138,7 +138,7 @@ func TestCallerFileLine(t *testing.T) {
 
 func TestReadStaticFile(t *testing.T) {
 
-	testFolder, testFolderErr := filepath.Abs("./testdata") // Need absolute path so that pkger.Open can work
+	testFolder, testFolderErr := filepath.Abs("./testdata") // Need absolute path so that the file is read from disk
 	if testFolderErr != nil {
 		t.Fatalf("Error loading test data folder: %v", testFolderErr)
 	}